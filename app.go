@@ -21,6 +21,12 @@ func NewApp(db *backend.DB) *App {
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
+	backend.Startup(ctx, a.RepMgr)
+}
+
+// shutdown is called when the app is closing; pending backend work is cancelled
+func (a *App) shutdown(ctx context.Context) {
+	backend.Shutdown(a.RepMgr)
 }
 
 
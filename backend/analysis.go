@@ -0,0 +1,166 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+)
+
+// EngineAnalysisEvent carries an EngineAnalysis to the frontend as an analysis started
+// by AnalyzeCurrentPosition progresses, and once more, marked done, when it ends.
+const EngineAnalysisEvent = "engine:analysis"
+
+// EngineInfo describes the analysis engine.
+type EngineInfo struct {
+	Running   bool           `json:"running"`
+	Analyzing bool           `json:"analyzing"`
+	Config    EngineConfig   `json:"config"` // as started, with options set since
+	Name      string         `json:"name"`
+	Author    string         `json:"author"`
+	Options   []EngineOption `json:"options"`
+}
+
+// analysisRun is an analysis in progress.
+type analysisRun struct {
+	cancel context.CancelFunc
+	done   chan struct{} // closed once the last update is sent
+}
+
+// StartEngine launches the UCI engine cfg describes, replacing any engine already
+// running, and sets its options. It returns what the engine reported about itself.
+func (m *RepertoireManager) StartEngine(cfg EngineConfig) (EngineInfo, error) {
+	m.engineMu.Lock()
+	defer m.engineMu.Unlock()
+	if err := m.stopEngine(); err != nil {
+		return EngineInfo{}, err
+	}
+	proc, err := m.engines(cfg)
+	if err != nil {
+		return EngineInfo{}, err
+	}
+	e, err := NewEngine(m.baseContext(), proc, cfg.Options)
+	if err != nil {
+		return EngineInfo{}, fmt.Errorf("failed to start engine: %w", err)
+	}
+	cfg.Options = maps.Clone(cfg.Options)
+	m.engine, m.engineCfg = e, cfg
+	return m.engineInfo(), nil
+}
+
+// StopEngine ends any analysis and quits the engine.
+func (m *RepertoireManager) StopEngine() error {
+	m.engineMu.Lock()
+	defer m.engineMu.Unlock()
+	return m.stopEngine()
+}
+
+func (m *RepertoireManager) stopEngine() error {
+	m.stopAnalysis()
+	if m.engine == nil {
+		return nil
+	}
+	err := m.engine.Close()
+	m.engine = nil
+	return err
+}
+
+// GetEngineInfo describes the running engine, if any.
+func (m *RepertoireManager) GetEngineInfo() EngineInfo {
+	m.engineMu.Lock()
+	defer m.engineMu.Unlock()
+	return m.engineInfo()
+}
+
+func (m *RepertoireManager) engineInfo() EngineInfo {
+	if m.engine == nil {
+		return EngineInfo{Options: []EngineOption{}}
+	}
+	info := EngineInfo{
+		Running: true,
+		Config:  m.engineCfg,
+		Name:    m.engine.Name(),
+		Author:  m.engine.Author(),
+		Options: m.engine.Options(),
+	}
+	if m.analysis != nil {
+		select {
+		case <-m.analysis.done:
+		default:
+			info.Analyzing = true
+		}
+	}
+	return info
+}
+
+// SetEngineOption sets one of the engine's UCI options, stopping any analysis first.
+func (m *RepertoireManager) SetEngineOption(name, value string) error {
+	m.engineMu.Lock()
+	defer m.engineMu.Unlock()
+	if m.engine == nil {
+		return fmt.Errorf("no engine running")
+	}
+	m.stopAnalysis()
+	if err := m.engine.SetOption(m.baseContext(), name, value); err != nil {
+		return err
+	}
+	if m.engineCfg.Options == nil {
+		m.engineCfg.Options = make(map[string]string)
+	}
+	m.engineCfg.Options[name] = value
+	return nil
+}
+
+// AnalyzeCurrentPosition starts the engine on the current position and returns at
+// once; the analysis arrives as EngineAnalysisEvent events, the best lines first with
+// their moves in SAN. A running analysis is stopped first. The analysis ends when it
+// reaches the limits, when the position changes or when StopAnalysis is called.
+func (m *RepertoireManager) AnalyzeCurrentPosition(limits AnalysisLimits) error {
+	m.engineMu.Lock()
+	defer m.engineMu.Unlock()
+	if m.engine == nil {
+		return fmt.Errorf("no engine running")
+	}
+	m.stopAnalysis()
+
+	// Take the context first so a move made meanwhile cancels this analysis.
+	ctx, cancel := context.WithCancel(m.positionContext())
+	m.mu.Lock()
+	fen := m.currentFEN
+	m.mu.Unlock()
+
+	run := &analysisRun{cancel: cancel, done: make(chan struct{})}
+	m.analysis = run
+	go func(e *Engine) {
+		defer close(run.done)
+		defer cancel()
+		a, err := e.Analyze(ctx, fen, limits, func(a EngineAnalysis) {
+			m.emitEvent(EngineAnalysisEvent, a)
+		})
+		if err != nil && !errors.Is(err, ErrCanceled) {
+			a.Error = err.Error()
+		}
+		if a.Lines == nil {
+			a.Lines = []PVLine{}
+		}
+		a.FEN, a.Done = fen, true
+		m.emitEvent(EngineAnalysisEvent, a)
+	}(m.engine)
+	return nil
+}
+
+// StopAnalysis stops the running analysis, if any, once its last update is sent.
+func (m *RepertoireManager) StopAnalysis() {
+	m.engineMu.Lock()
+	defer m.engineMu.Unlock()
+	m.stopAnalysis()
+}
+
+func (m *RepertoireManager) stopAnalysis() {
+	if m.analysis == nil {
+		return
+	}
+	m.analysis.cancel()
+	<-m.analysis.done
+	m.analysis = nil
+}
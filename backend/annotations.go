@@ -0,0 +1,265 @@
+package backend
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Annotation is the commentary attached to a position or a move: free text, NAG
+// symbols and board markup in the PGN [%cal]/[%csl] format.
+type Annotation struct {
+	Comment string   `json:"comment"`
+	NAGs    []int    `json:"nags"`    // numeric annotation glyphs, e.g. 1 for "!", 14 for "+="
+	Arrows  []string `json:"arrows"`  // colour, from and to square, e.g. "Ge2e4"
+	Squares []string `json:"squares"` // colour and square, e.g. "Rd4"
+}
+
+// Empty reports whether there is nothing to store.
+func (a Annotation) Empty() bool {
+	return a.Comment == "" && len(a.NAGs) == 0 && len(a.Arrows) == 0 && len(a.Squares) == 0
+}
+
+var (
+	markupCommand = regexp.MustCompile(`\[%(cal|csl)\s+([^\]]*)\]`)
+	arrowSpec     = regexp.MustCompile(`^[RGBY][a-h][1-8][a-h][1-8]$`)
+	squareSpec    = regexp.MustCompile(`^[RGBY][a-h][1-8]$`)
+)
+
+// Validate checks NAG codes and markup against the formats PGN readers accept.
+func (a Annotation) Validate() error {
+	for _, n := range a.NAGs {
+		if n < 0 || n > 255 {
+			return fmt.Errorf("invalid NAG %d", n)
+		}
+	}
+	for _, s := range a.Arrows {
+		if !arrowSpec.MatchString(s) {
+			return fmt.Errorf("invalid arrow %q, expected colour and two squares such as Ge2e4", s)
+		}
+	}
+	for _, s := range a.Squares {
+		if !squareSpec.MatchString(s) {
+			return fmt.Errorf("invalid square %q, expected colour and square such as Rd4", s)
+		}
+	}
+	return nil
+}
+
+// parseComment splits a PGN comment into its text and [%cal]/[%csl] markup.
+// Other embedded commands such as [%clk] are kept in the text.
+func parseComment(text string) Annotation {
+	var a Annotation
+	for _, m := range markupCommand.FindAllStringSubmatch(text, -1) {
+		for _, spec := range strings.Split(m[2], ",") {
+			spec = strings.TrimSpace(spec)
+			switch {
+			case m[1] == "cal" && arrowSpec.MatchString(spec):
+				a.Arrows = append(a.Arrows, spec)
+			case m[1] == "csl" && squareSpec.MatchString(spec):
+				a.Squares = append(a.Squares, spec)
+			}
+		}
+	}
+	a.Comment = strings.Join(strings.Fields(markupCommand.ReplaceAllString(text, " ")), " ")
+	return a
+}
+
+// pgnComment renders the text and markup of a as a PGN comment body.
+func (a Annotation) pgnComment() string {
+	parts := make([]string, 0, 3)
+	if a.Comment != "" {
+		parts = append(parts, a.Comment)
+	}
+	if len(a.Squares) > 0 {
+		parts = append(parts, "[%csl "+strings.Join(a.Squares, ",")+"]")
+	}
+	if len(a.Arrows) > 0 {
+		parts = append(parts, "[%cal "+strings.Join(a.Arrows, ",")+"]")
+	}
+	return strings.Join(parts, " ")
+}
+
+// isMoveNAG reports whether a NAG judges the move itself ($1 to $9) rather than the
+// position it leads to, which decides whether it is stored on the edge or the node.
+func isMoveNAG(n int) bool {
+	return n >= 1 && n <= 9
+}
+
+func formatNAGs(nags []int) string {
+	parts := make([]string, len(nags))
+	for i, n := range nags {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+func parseNAGs(s string) []int {
+	nags := []int{}
+	for _, part := range splitList(s) {
+		if n, err := strconv.Atoi(part); err == nil {
+			nags = append(nags, n)
+		}
+	}
+	return nags
+}
+
+// scanAnnotation reads the comment, nags, arrows and squares columns, in that order.
+func scanAnnotation(row interface{ Scan(...any) error }, extra ...any) (Annotation, error) {
+	var a Annotation
+	var nags, arrows, squares string
+	if err := row.Scan(append(extra, &a.Comment, &nags, &arrows, &squares)...); err != nil {
+		return Annotation{}, err
+	}
+	a.NAGs = parseNAGs(nags)
+	a.Arrows = splitList(arrows)
+	a.Squares = splitList(squares)
+	return a, nil
+}
+
+func annotationArgs(a Annotation) []any {
+	return []any{a.Comment, formatNAGs(a.NAGs), strings.Join(a.Arrows, ","), strings.Join(a.Squares, ",")}
+}
+
+// edgeKey identifies a move by its position and SAN.
+type edgeKey struct {
+	parentFEN string
+	move      string
+}
+
+// repAnnotations holds every non-empty annotation of a repertoire.
+type repAnnotations struct {
+	nodes map[string]Annotation
+	edges map[edgeKey]Annotation
+}
+
+func loadAnnotations(ctx context.Context, q queryer, repID int64) (*repAnnotations, error) {
+	ann := &repAnnotations{nodes: map[string]Annotation{}, edges: map[edgeKey]Annotation{}}
+
+	rows, err := q.QueryContext(ctx,
+		`SELECT fen, comment, nags, arrows, squares FROM nodes
+		 WHERE rep_id = ? AND (comment <> '' OR nags <> '' OR arrows <> '' OR squares <> '')`, repID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load annotations: %w", err)
+	}
+	for rows.Next() {
+		var fen string
+		a, err := scanAnnotation(rows, &fen)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ann.nodes[fen] = a
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = q.QueryContext(ctx,
+		`SELECT parent_fen, move, comment, nags, arrows, squares FROM edges
+		 WHERE rep_id = ? AND (comment <> '' OR nags <> '' OR arrows <> '' OR squares <> '')`, repID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load annotations: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k edgeKey
+		a, err := scanAnnotation(rows, &k.parentFEN, &k.move)
+		if err != nil {
+			return nil, err
+		}
+		ann.edges[k] = a
+	}
+	return ann, rows.Err()
+}
+
+// GetPositionAnnotation returns the annotation of the current position.
+func (m *RepertoireManager) GetPositionAnnotation() (Annotation, error) {
+	repID, fen, err := m.currentPosition()
+	if err != nil {
+		return Annotation{}, err
+	}
+	a, err := scanAnnotation(m.db.QueryRowContext(m.baseContext(),
+		`SELECT comment, nags, arrows, squares FROM nodes WHERE rep_id = ? AND fen = ?`,
+		repID, positionKey(fen)))
+	if err == sql.ErrNoRows {
+		return Annotation{NAGs: []int{}, Arrows: []string{}, Squares: []string{}}, nil
+	}
+	if err != nil {
+		return Annotation{}, fmt.Errorf("failed to get annotation: %w", err)
+	}
+	return a, nil
+}
+
+// SetPositionAnnotation replaces the annotation of the current position, adding the
+// position to the repertoire if it was only reached by browsing.
+func (m *RepertoireManager) SetPositionAnnotation(a Annotation) error {
+	repID, fen, err := m.currentPosition()
+	if err != nil {
+		return err
+	}
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	_, err = m.db.ExecContext(m.baseContext(),
+		`INSERT INTO nodes (fen, rep_id, display_fen, comment, nags, arrows, squares) VALUES (?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (fen, rep_id) DO UPDATE SET
+		   comment = excluded.comment, nags = excluded.nags,
+		   arrows = excluded.arrows, squares = excluded.squares`,
+		append([]any{positionKey(fen), repID, fen}, annotationArgs(a)...)...)
+	if err != nil {
+		return fmt.Errorf("failed to save annotation: %w", err)
+	}
+	return nil
+}
+
+// GetMoveAnnotation returns the annotation of moveSAN from the current position.
+func (m *RepertoireManager) GetMoveAnnotation(moveSAN string) (Annotation, error) {
+	repID, fen, err := m.currentPosition()
+	if err != nil {
+		return Annotation{}, err
+	}
+	a, err := scanAnnotation(m.db.QueryRowContext(m.baseContext(),
+		`SELECT comment, nags, arrows, squares FROM edges WHERE rep_id = ? AND parent_fen = ? AND move = ?`,
+		repID, positionKey(fen), canonicalSAN(fen, moveSAN)))
+	if err == sql.ErrNoRows {
+		return Annotation{}, fmt.Errorf("edge not found")
+	}
+	if err != nil {
+		return Annotation{}, fmt.Errorf("failed to get annotation: %w", err)
+	}
+	return a, nil
+}
+
+// SetMoveAnnotation replaces the annotation of moveSAN from the current position.
+func (m *RepertoireManager) SetMoveAnnotation(moveSAN string, a Annotation) error {
+	repID, fen, err := m.currentPosition()
+	if err != nil {
+		return err
+	}
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	res, err := m.db.ExecContext(m.baseContext(),
+		`UPDATE edges SET comment = ?, nags = ?, arrows = ?, squares = ?
+		 WHERE rep_id = ? AND parent_fen = ? AND move = ?`,
+		append(annotationArgs(a), repID, positionKey(fen), canonicalSAN(fen, moveSAN))...)
+	if err != nil {
+		return fmt.Errorf("failed to save annotation: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("edge not found")
+	}
+	return nil
+}
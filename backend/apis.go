@@ -1,10 +1,13 @@
 package backend
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"net/url"
+	"time"
 )
 
 // Structs matching Explorer API response
@@ -29,19 +32,185 @@ type ExplorerResponse struct {
 	Opening Opening `json:"opening"`
 }
 
-// Fetch data from Lichess Explorer
-func FetchExplorerData(fen string, elo int) (ExplorerResponse, error) {
-	url := fmt.Sprintf("https://explorer.lichess.ovh/lichess?variant=standard&speeds=rapid&ratings=%d&fen=%s", elo, url.QueryEscape(fen))
+// ExplorerProvider supplies opening statistics for a position.
+type ExplorerProvider interface {
+	Explore(ctx context.Context, fen string, q ExplorerQuery) (ExplorerResponse, error)
+}
+
+// LichessExplorerURL is the default endpoint of the Lichess opening explorer.
+const LichessExplorerURL = "https://explorer.lichess.ovh"
+
+// LichessExplorer fetches statistics from the Lichess opening explorer over HTTP.
+// It serves the lichess, masters and player databases, retrying rate-limited and
+// failed requests with exponential backoff.
+type LichessExplorer struct {
+	BaseURL       string
+	Client        *http.Client
+	Timeout       time.Duration // per attempt for the lichess and masters databases
+	StreamTimeout time.Duration // per attempt for the player stream, which may take a while to index
+	MaxRetries    int
+	Backoff       time.Duration // wait before the first retry, doubled on each attempt
+	MaxBackoff    time.Duration
+	Limiter       *RateLimiter
+	// Progress, if set, receives each partial result while a player query is still indexing.
+	Progress func(fen string, partial ExplorerResponse)
+}
+
+// NewLichessExplorer returns a client for the public Lichess explorer.
+func NewLichessExplorer() *LichessExplorer {
+	return &LichessExplorer{
+		BaseURL:       LichessExplorerURL,
+		Client:        http.DefaultClient,
+		Timeout:       10 * time.Second,
+		StreamTimeout: 2 * time.Minute,
+		MaxRetries:    3,
+		Backoff:       time.Second,
+		MaxBackoff:    time.Minute,
+		Limiter:       explorerLimiter,
+	}
+}
+
+// Explore queries the database selected by q with the query's filters.
+func (l *LichessExplorer) Explore(ctx context.Context, fen string, q ExplorerQuery) (ExplorerResponse, error) {
+	switch q.Database {
+	case ExplorerLichess, ExplorerMasters, ExplorerPlayer:
+	default:
+		return ExplorerResponse{}, fmt.Errorf("explorer database %q is not supported", q.Database)
+	}
+	params := q.Values()
+	params.Set("fen", fen)
+	url := fmt.Sprintf("%s/%s?%s", l.BaseURL, q.Database, params.Encode())
+
+	for attempt := 0; ; attempt++ {
+		data, err := l.fetch(ctx, url, fen, q.Database == ExplorerPlayer)
+		if err == nil {
+			return data, nil
+		}
+		var xerr *ExplorerError
+		if !errors.As(err, &xerr) || !xerr.retryable() || attempt >= l.MaxRetries {
+			return ExplorerResponse{}, err
+		}
+		wait := backoff(l.Backoff, l.MaxBackoff, attempt)
+		if xerr.RetryAfter > 0 {
+			wait = xerr.RetryAfter
+		}
+		if xerr.Kind == ErrExplorerRateLimited && l.Limiter != nil {
+			// Every request must back off, not just this one.
+			l.Limiter.Pause(wait)
+			wait = 0
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return ExplorerResponse{}, err
+		}
+	}
+}
+
+// fetch performs a single attempt, bounded by the per-attempt timeout.
+func (l *LichessExplorer) fetch(ctx context.Context, url, fen string, stream bool) (ExplorerResponse, error) {
+	if l.Limiter != nil {
+		if err := l.Limiter.Wait(ctx); err != nil {
+			return ExplorerResponse{}, err
+		}
+	}
+	timeout := l.Timeout
+	if stream {
+		timeout = l.StreamTimeout
+	}
+	attemptCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(attemptCtx, http.MethodGet, url, nil)
 	if err != nil {
 		return ExplorerResponse{}, err
 	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := l.Client.Do(req)
+	if err != nil {
+		return ExplorerResponse{}, l.transportError(ctx, err)
+	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ExplorerResponse{}, classifyStatus(resp)
+	}
 
+	body := &bodyReader{r: resp.Body}
 	var data ExplorerResponse
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return ExplorerResponse{}, err
+	if stream {
+		data, err = l.readPlayerStream(fen, body)
+	} else {
+		err = json.NewDecoder(body).Decode(&data)
+	}
+	switch {
+	case body.err != nil:
+		return ExplorerResponse{}, l.transportError(ctx, body.err)
+	case err != nil:
+		// The whole body arrived but is not what the explorer should send; asking
+		// again will not help.
+		return ExplorerResponse{}, &ExplorerError{Kind: ErrExplorerResponse, StatusCode: resp.StatusCode, Err: err}
 	}
 	return data, nil
 }
+
+// bodyReader remembers a failure to read the response, so a broken connection can be
+// told apart from a response that does not decode.
+type bodyReader struct {
+	r   io.Reader
+	err error
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+// transportError classifies a failure to get or read a response. Cancellation of the
+// caller's context is returned as is; a per-attempt timeout becomes ErrExplorerTimeout.
+func (l *LichessExplorer) transportError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &ExplorerError{Kind: ErrExplorerTimeout, Err: err}
+	}
+	return &ExplorerError{Kind: ErrExplorerUnavailable, Err: err}
+}
+
+// readPlayerStream consumes the NDJSON stream of the player endpoint. Each line is a
+// complete snapshot covering the games indexed so far; the last one is the result.
+func (l *LichessExplorer) readPlayerStream(fen string, body io.Reader) (ExplorerResponse, error) {
+	dec := json.NewDecoder(body)
+	var (
+		last ExplorerResponse
+		seen bool
+	)
+	for {
+		var snap ExplorerResponse
+		err := dec.Decode(&snap)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return ExplorerResponse{}, fmt.Errorf("failed to decode player stream: %w", err)
+		}
+		last, seen = snap, true
+		if l.Progress != nil {
+			l.Progress(fen, snap)
+		}
+	}
+	if !seen {
+		return ExplorerResponse{}, fmt.Errorf("empty player stream")
+	}
+	return last, nil
+}
+
+// Fetch data from Lichess Explorer
+func FetchExplorerData(fen string, elo int) (ExplorerResponse, error) {
+	return NewLichessExplorer().Explore(context.Background(), fen, DefaultExplorerQuery(elo))
+}
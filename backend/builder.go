@@ -0,0 +1,259 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+)
+
+// How BuildRepertoire picks the repertoire side's move.
+const (
+	PolicyBestScore = "score"   // best score for the repertoire side among moves with enough games
+	PolicyPopular   = "popular" // most played
+)
+
+// defaultBuildMinGames is the sample a move needs by default to be picked by score.
+const defaultBuildMinGames = 100
+
+// BuildOptions controls BuildRepertoire.
+type BuildOptions struct {
+	RootFEN  string  `json:"rootFen"`  // position to build from, the start position if empty
+	Depth    int     `json:"depth"`    // plies below the root lines may reach
+	Coverage float64 `json:"coverage"` // as Repertoire.Coverage; the repertoire's own setting if zero
+	Policy   string  `json:"policy"`   // PolicyBestScore (default) or PolicyPopular
+	MinGames int     `json:"minGames"` // games a move needs to be picked by score, 100 if zero
+}
+
+// BuildResult summarises what BuildRepertoire added.
+type BuildResult struct {
+	RepID          int64 `json:"repId"`
+	NewPositions   int   `json:"newPositions"`
+	KnownPositions int   `json:"knownPositions"`
+	NewMoves       int   `json:"newMoves"`
+	KnownMoves     int   `json:"knownMoves"`
+	Unanswered     int   `json:"unanswered"` // positions where the explorer knew no move to play
+}
+
+// buildItem is a position waiting to be extended, as a full FEN.
+type buildItem struct {
+	fen   string
+	depth int
+	reach float64
+}
+
+// BuildRepertoire extends a repertoire from the explorer. From the root, the repertoire
+// side's move is picked by opts.Policy, falling back to the most played move when none
+// has enough games for the score policy, and every opponent reply whose line is reached
+// at least as often as the coverage threshold is followed, as in FindCoverageGaps.
+// Positions that already have a move for the repertoire side keep it and are followed
+// along their primary move. Lines stay within opts.Depth plies of the root and end with
+// the repertoire side's move. A root that is not yet a position of the repertoire is
+// added to it as a recorded root, so the lines built from it are walked like the rest of
+// the repertoire. Everything found is written in one transaction at the end.
+func (m *RepertoireManager) BuildRepertoire(repID int64, opts BuildOptions) (BuildResult, error) {
+	switch opts.Policy {
+	case "":
+		opts.Policy = PolicyBestScore
+	case PolicyBestScore, PolicyPopular:
+	default:
+		return BuildResult{}, fmt.Errorf("unknown move policy %q", opts.Policy)
+	}
+	if opts.Depth < 1 {
+		return BuildResult{}, fmt.Errorf("depth must be at least 1")
+	}
+	if opts.MinGames <= 0 {
+		opts.MinGames = defaultBuildMinGames
+	}
+	ctx, done := m.beginOperation()
+	defer done()
+
+	color, err := m.repertoireColor(ctx, repID)
+	if err != nil {
+		return BuildResult{}, canceledError(err)
+	}
+	threshold := coverageThreshold(opts.Coverage)
+	if opts.Coverage == 0 {
+		if threshold, err = m.repertoireThreshold(ctx, repID); err != nil {
+			return BuildResult{}, canceledError(err)
+		}
+	}
+	q, err := m.repertoireExplorerQuery(ctx, repID)
+	if err != nil {
+		return BuildResult{}, canceledError(err)
+	}
+	g, err := loadGraph(ctx, m.db, repID)
+	if err != nil {
+		return BuildResult{}, canceledError(err)
+	}
+	g.withoutDeprecated()
+
+	root, newRoot := g.displayFEN(startKey), ""
+	if opts.RootFEN != "" {
+		pos, err := positionFromFEN(opts.RootFEN)
+		if err != nil {
+			return BuildResult{}, err
+		}
+		root = pos.String()
+		if key := positionKey(root); g.nodes[key] {
+			root = g.displayFEN(key)
+		} else {
+			newRoot = root
+		}
+	}
+
+	res := BuildResult{RepID: repID}
+	var found []Edge // ParentFEN and ChildFEN are full FENs
+	queue := []buildItem{{fen: root, reach: 1}}
+	seen := map[string]bool{positionKey(root): true}
+	push := func(parent buildItem, fen string, reach float64) {
+		if key := positionKey(fen); !seen[key] {
+			seen[key] = true
+			queue = append(queue, buildItem{fen: fen, depth: parent.depth + 1, reach: reach})
+		}
+	}
+	for len(queue) > 0 {
+		if err := checkContext(ctx); err != nil {
+			return BuildResult{}, err
+		}
+		it := queue[0]
+		queue = queue[1:]
+		key := positionKey(it.fen)
+
+		if sideToMove(it.fen) == color {
+			if stored := g.children[key]; len(stored) > 0 {
+				push(it, g.displayFEN(primaryEdge(stored).ChildFEN), it.reach)
+				continue
+			}
+			moves, err := m.moveFrequencies(ctx, it.fen, q)
+			if err != nil {
+				return BuildResult{}, canceledError(err)
+			}
+			mv, ok := chooseBuildMove(moves, opts, color)
+			if !ok {
+				res.Unanswered++
+				continue
+			}
+			found = append(found, Edge{ParentFEN: it.fen, ChildFEN: mv.FEN, MoveSAN: mv.SAN})
+			push(it, mv.FEN, it.reach)
+			continue
+		}
+
+		// Leave room for the answer to the reply.
+		if it.depth+2 > opts.Depth {
+			continue
+		}
+		replies, err := m.moveFrequencies(ctx, it.fen, q)
+		if err != nil {
+			return BuildResult{}, canceledError(err)
+		}
+		for _, r := range replies {
+			reach := it.reach * r.Frequency
+			if reach < threshold {
+				continue
+			}
+			found = append(found, Edge{ParentFEN: it.fen, ChildFEN: r.FEN, MoveSAN: r.SAN})
+			push(it, r.FEN, reach)
+		}
+	}
+
+	if err := m.writeBuild(ctx, repID, color, newRoot, found, &res); err != nil {
+		return BuildResult{}, canceledError(err)
+	}
+	return res, nil
+}
+
+// BuildNewRepertoire creates a repertoire and builds it from the explorer, removing it
+// again if the build fails.
+func (m *RepertoireManager) BuildNewRepertoire(name, color string, elo int, opts BuildOptions) (BuildResult, error) {
+	repID, err := m.Create(name, color, elo)
+	if err != nil {
+		return BuildResult{}, err
+	}
+	res, err := m.BuildRepertoire(repID, opts)
+	if err != nil {
+		if delErr := m.Delete(repID); delErr != nil {
+			return BuildResult{}, fmt.Errorf("%w (and failed to remove the repertoire: %v)", err, delErr)
+		}
+		return BuildResult{}, err
+	}
+	return res, nil
+}
+
+// primaryEdge returns the primary move among a position's moves, or the first one.
+func primaryEdge(edges []Edge) Edge {
+	for _, e := range edges {
+		if e.Kind == EdgePrimary {
+			return e
+		}
+	}
+	return edges[0]
+}
+
+// chooseBuildMove picks the repertoire side's move by the build policy.
+func chooseBuildMove(moves []moveFrequency, opts BuildOptions, color string) (moveFrequency, bool) {
+	var best moveFrequency
+	found := false
+	if opts.Policy == PolicyBestScore {
+		for _, mv := range moves {
+			if mv.Games >= opts.MinGames && (!found || mv.score(color) > best.score(color)) {
+				best, found = mv, true
+			}
+		}
+		if found {
+			return best, true
+		}
+	}
+	for _, mv := range moves {
+		if !found || mv.Games > best.Games {
+			best, found = mv, true
+		}
+	}
+	return best, found
+}
+
+// writeBuild stores the moves found by BuildRepertoire like an imported PGN would,
+// after adding newRoot, if set, as a root of the repertoire.
+func (m *RepertoireManager) writeBuild(ctx context.Context, repID int64, color, newRoot string, edges []Edge, res *BuildResult) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	imp := &pgnImporter{
+		ctx:     ctx,
+		tx:      tx,
+		repID:   repID,
+		color:   color,
+		seen:    make(map[string]bool),
+		touched: make(map[string]bool),
+	}
+	if newRoot != "" {
+		if err := imp.visit(newRoot); err != nil {
+			return err
+		}
+		if err := markRoot(ctx, tx, repID, positionKey(newRoot)); err != nil {
+			return err
+		}
+	}
+	for _, e := range edges {
+		for _, fen := range []string{e.ParentFEN, e.ChildFEN} {
+			if err := imp.visit(fen); err != nil {
+				return err
+			}
+		}
+		if err := imp.edge(positionKey(e.ParentFEN), positionKey(e.ChildFEN), e.MoveSAN); err != nil {
+			return err
+		}
+	}
+	if err := imp.scheduleTouched(m.nowSQL()); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	res.NewPositions = imp.result.NewPositions
+	res.KnownPositions = imp.result.KnownPositions
+	res.NewMoves = imp.result.NewMoves
+	res.KnownMoves = imp.result.KnownMoves
+	return nil
+}
@@ -0,0 +1,80 @@
+package backend
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBuildNewRepertoireFromCustomRoot(t *testing.T) {
+	fe := NewFakeExplorer()
+	m, _ := newTestManager(t, WithExplorer(fe))
+	const ruy = "r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3"
+	bb5, err := ApplyMoveSAN(ruy, "Bb5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a6, err := ApplyMoveSAN(bb5, "a6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nf6, err := ApplyMoveSAN(bb5, "Nf6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fe.Responses[ruy] = ExplorerResponse{White: 180, Black: 140, Draws: 30, Moves: []Move{
+		{SAN: "Bc4", White: 60, Black: 80, Draws: 10},
+		{SAN: "Bb5", White: 120, Black: 60, Draws: 20},
+	}}
+	fe.Responses[bb5] = explorerMoves(map[string]int{"a6": 70, "Nf6": 29, "f5": 1})
+	fe.Responses[a6] = explorerMoves(map[string]int{"Ba4": 100})
+	fe.Responses[nf6] = explorerMoves(map[string]int{"O-O": 100, "d3": 50})
+
+	res, err := m.BuildNewRepertoire("Ruy Lopez", "white", 1500, BuildOptions{RootFEN: ruy, Depth: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3...f5 is played in 1% of games, below the default coverage of one game in 50.
+	if res.NewPositions != 6 || res.NewMoves != 5 || res.KnownMoves != 0 || res.Unanswered != 0 {
+		t.Errorf("result = %+v, want 6 positions and 5 moves added", res)
+	}
+
+	g, err := loadGraph(m.baseContext(), m.db, res.RepID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roots := g.roots(); !slices.Equal(roots, []string{startKey, positionKey(ruy)}) {
+		t.Errorf("roots = %q, want the start position and the build root", roots)
+	}
+	if g.displayFEN(positionKey(ruy)) != ruy {
+		t.Errorf("root shown as %q, want %q", g.displayFEN(positionKey(ruy)), ruy)
+	}
+	want := map[string][]string{ruy: {"Bb5"}, bb5: {"a6", "Nf6"}, a6: {"Ba4"}, nf6: {"O-O"}}
+	for fen, moves := range want {
+		var got []string
+		for _, e := range g.children[positionKey(fen)] {
+			got = append(got, e.MoveSAN)
+		}
+		if !slices.Equal(got, moves) {
+			t.Errorf("moves from %s = %q, want %q", fen, got, moves)
+		}
+	}
+	if report, err := m.CheckIntegrity(res.RepID, false); err != nil || !report.OK() {
+		t.Errorf("integrity after build: %+v, %v", report, err)
+	}
+}
+
+func TestBuildNewRepertoireRemovesFailedBuild(t *testing.T) {
+	m, _ := newTestManager(t)
+	if _, err := m.BuildNewRepertoire("Broken", "white", 1500, BuildOptions{RootFEN: "not a fen", Depth: 2}); err == nil {
+		t.Fatal("build from an invalid root succeeded")
+	}
+	reps, err := m.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range reps {
+		if r.Name == "Broken" {
+			t.Error("repertoire of the failed build kept")
+		}
+	}
+}
@@ -1,21 +1,122 @@
 package backend
 import (
     "fmt"
+    "regexp"
+    "strings"
+
     "github.com/notnil/chess"
 )
 
-// ApplyMoveSAN takes a FEN and a SAN move string, returns the new FEN.
+// StartFEN is the standard initial position every repertoire is rooted at.
+const StartFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// ApplyMove plays move on fen and returns the move in canonical SAN, with check and
+// mate suffixes and only the disambiguation it needs, together with the new FEN.
+// The move may be given in SAN ("Nf3", "exd5", "O-O", "e8=Q+"), UCI ("g1f3", "e7e8q")
+// or long algebraic notation ("Ng1-f3", "e4xd5", "e7-e8=Q").
+func ApplyMove(fen, move string) (string, string, error) {
+    pos, err := positionFromFEN(fen)
+    if err != nil {
+        return "", "", err
+    }
+    mv, san, err := decodeMove(pos, move)
+    if err != nil {
+        return "", "", err
+    }
+    return san, pos.Update(mv).String(), nil
+}
+
+// ApplyMoveSAN takes a FEN and a move in any notation ApplyMove accepts, returns the new FEN.
 func ApplyMoveSAN(fen, san string) (string, error) {
-    pos, err := chess.FEN(fen)
+    _, next, err := ApplyMove(fen, san)
+    return next, err
+}
+
+// positionFromFEN decodes a FEN into a position that moves can be replayed on.
+func positionFromFEN(fen string) (*chess.Position, error) {
+    opt, err := chess.FEN(fen)
     if err != nil {
-        return "", fmt.Errorf("invalid FEN: %w", err)
+        return nil, fmt.Errorf("invalid FEN: %w", err)
+    }
+    return chess.NewGame(opt).Position(), nil
+}
+
+var (
+    uciMove = regexp.MustCompile(`^([a-h][1-8])([a-h][1-8])([qrbn])?$`)
+    // sanMove also matches long algebraic notation once "-" separators are removed.
+    sanMove = regexp.MustCompile(`^([KQRBN])?([a-h])?([1-8])?x?([a-h][1-8])(?:=?([QRBNqrbn]))?$`)
+)
+
+var promoPieces = map[string]chess.PieceType{
+    "q": chess.Queen, "r": chess.Rook, "b": chess.Bishop, "n": chess.Knight,
+}
+
+var sanPieces = map[string]chess.PieceType{
+    "": chess.Pawn, "K": chess.King, "Q": chess.Queen, "R": chess.Rook, "B": chess.Bishop, "N": chess.Knight,
+}
+
+// decodeMove finds the legal move s denotes in pos and returns it with its canonical SAN.
+// Check, mate and annotation suffixes are ignored, so are superfluous disambiguation and
+// a missing capture sign, but a move that fits several legal moves is rejected. Castling
+// is written O-O, O-O-O (or with zeros) or as the king's move in UCI, such as "e1g1".
+func decodeMove(pos *chess.Position, s string) (*chess.Move, string, error) {
+    text := strings.TrimSpace(s)
+    text = strings.TrimSuffix(text, "e.p.")
+    text = strings.TrimRight(text, "+#!? ")
+
+    var candidates []*chess.Move
+    switch strings.ReplaceAll(text, "0", "O") {
+    case "O-O":
+        candidates = movesWithTag(pos, chess.KingSideCastle)
+    case "O-O-O":
+        candidates = movesWithTag(pos, chess.QueenSideCastle)
+    default:
+        if m := uciMove.FindStringSubmatch(text); m != nil {
+            candidates = matchMoves(pos, func(mv *chess.Move) bool {
+                return mv.S1().String() == m[1] && mv.S2().String() == m[2] && mv.Promo() == promoPieces[m[3]]
+            })
+            if len(candidates) > 0 {
+                break
+            }
+        }
+        m := sanMove.FindStringSubmatch(strings.ReplaceAll(text, "-", ""))
+        if m == nil {
+            return nil, "", fmt.Errorf("invalid move: %s", s)
+        }
+        piece, file, rank, to := sanPieces[m[1]], m[2], m[3], m[4]
+        promo := promoPieces[strings.ToLower(m[5])]
+        // A king move to its castling square is not castling; that takes O-O or UCI.
+        candidates = matchMoves(pos, func(mv *chess.Move) bool {
+            if mv.HasTag(chess.KingSideCastle) || mv.HasTag(chess.QueenSideCastle) {
+                return false
+            }
+            from := mv.S1().String()
+            return pos.Board().Piece(mv.S1()).Type() == piece && mv.S2().String() == to &&
+                (file == "" || from[:1] == file) && (rank == "" || from[1:] == rank) &&
+                mv.Promo() == promo
+        })
+    }
+
+    switch len(candidates) {
+    case 0:
+        return nil, "", fmt.Errorf("illegal move: %s", s)
+    case 1:
+        return candidates[0], chess.AlgebraicNotation{}.Encode(pos, candidates[0]), nil
+    default:
+        return nil, "", fmt.Errorf("ambiguous move: %s", s)
     }
+}
 
-    game := chess.NewGame(pos, chess.UseNotation(chess.AlgebraicNotation{}))
+func movesWithTag(pos *chess.Position, tag chess.MoveTag) []*chess.Move {
+    return matchMoves(pos, func(mv *chess.Move) bool { return mv.HasTag(tag) })
+}
 
-    move := game.MoveStr(san)
-    if move != nil {
-        return "", fmt.Errorf("invalid SAN move: %s", san)
+func matchMoves(pos *chess.Position, match func(*chess.Move) bool) []*chess.Move {
+    var out []*chess.Move
+    for _, mv := range pos.ValidMoves() {
+        if match(mv) {
+            out = append(out, mv)
+        }
     }
-    return game.Position().String(), nil
+    return out
 }
@@ -0,0 +1,110 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyMove(t *testing.T) {
+	const (
+		castling  = "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1"
+		promotion = "7k/4P3/8/8/8/8/8/K7 w - - 0 1"
+		enPassant = "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 3"
+		knights   = "4k3/8/8/8/8/5N2/8/1N2K3 w - - 0 1"
+		scandi    = "rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 2"
+	)
+	tests := []struct {
+		name    string
+		fen     string
+		move    string
+		san     string // canonical SAN, when the move is accepted
+		next    string // resulting FEN, checked when set
+		wantErr string // error prefix, when the move is rejected
+	}{
+		{name: "SAN", fen: StartFEN, move: "Nf3", san: "Nf3"},
+		{name: "UCI", fen: StartFEN, move: "g1f3", san: "Nf3"},
+		{name: "LAN", fen: StartFEN, move: "Ng1-f3", san: "Nf3"},
+		{name: "pawn LAN", fen: StartFEN, move: "e2-e4", san: "e4"},
+		{name: "suffixes ignored", fen: StartFEN, move: "Nf3!?", san: "Nf3"},
+		{name: "capture LAN", fen: scandi, move: "e4xd5", san: "exd5"},
+		{name: "capture UCI", fen: scandi, move: "e4d5", san: "exd5"},
+		{name: "missing capture sign", fen: scandi, move: "ed5", san: "exd5"},
+
+		{name: "O-O", fen: castling, move: "O-O", san: "O-O", next: "r3k2r/8/8/8/8/8/8/R4RK1 b kq - 1 1"},
+		{name: "0-0", fen: castling, move: "0-0", san: "O-O"},
+		{name: "O-O-O", fen: castling, move: "O-O-O", san: "O-O-O", next: "r3k2r/8/8/8/8/8/8/2KR3R b kq - 1 1"},
+		{name: "0-0-0", fen: castling, move: "0-0-0", san: "O-O-O"},
+		{name: "castling UCI", fen: castling, move: "e1g1", san: "O-O"},
+		{name: "queenside castling UCI", fen: castling, move: "e1c1", san: "O-O-O"},
+		{name: "king to castling square", fen: castling, move: "Kg1", wantErr: "illegal move"},
+		{name: "king to castling square LAN", fen: castling, move: "Ke1-g1", wantErr: "illegal move"},
+		{name: "plain king move", fen: castling, move: "Kf1", san: "Kf1"},
+		{name: "no castling rights", fen: "r3k2r/8/8/8/8/8/8/R3K2R w - - 0 1", move: "O-O", wantErr: "illegal move"},
+
+		{name: "promotion with check", fen: promotion, move: "e8=Q+", san: "e8=Q+", next: "4Q2k/8/8/8/8/8/8/K7 b - - 0 1"},
+		{name: "promotion without suffix", fen: promotion, move: "e8=Q", san: "e8=Q+"},
+		{name: "promotion without =", fen: promotion, move: "e8Q", san: "e8=Q+"},
+		{name: "promotion UCI", fen: promotion, move: "e7e8q", san: "e8=Q+"},
+		{name: "underpromotion UCI", fen: promotion, move: "e7e8n", san: "e8=N"},
+		{name: "promotion LAN", fen: promotion, move: "e7-e8=R", san: "e8=R+"},
+		{name: "promotion piece missing", fen: promotion, move: "e8", wantErr: "illegal move"},
+
+		{name: "en passant", fen: enPassant, move: "exd6 e.p.", san: "exd6",
+			next: "rnbqkbnr/ppp1p1pp/3P4/5p2/8/8/PPPP1PPP/RNBQKBNR b KQkq - 0 3"},
+		{name: "en passant plain", fen: enPassant, move: "exd6", san: "exd6"},
+		{name: "en passant UCI", fen: enPassant, move: "e5d6", san: "exd6"},
+		{name: "en passant square expired", fen: enPassant, move: "exf6", wantErr: "illegal move"},
+
+		{name: "ambiguous", fen: knights, move: "Nd2", wantErr: "ambiguous move"},
+		{name: "file disambiguation", fen: knights, move: "Nbd2", san: "Nbd2"},
+		{name: "other knight", fen: knights, move: "Nfd2", san: "Nfd2"},
+		{name: "square disambiguation", fen: knights, move: "Nb1d2", san: "Nbd2"},
+		{name: "disambiguated UCI", fen: knights, move: "f3d2", san: "Nfd2"},
+		{name: "redundant file", fen: StartFEN, move: "Ngf3", san: "Nf3"},
+		{name: "redundant rank", fen: StartFEN, move: "N1f3", san: "Nf3"},
+		{name: "wrong disambiguation", fen: StartFEN, move: "Nbf3", wantErr: "illegal move"},
+
+		{name: "illegal", fen: StartFEN, move: "e5", wantErr: "illegal move"},
+		{name: "garbage", fen: StartFEN, move: "Zz9", wantErr: "invalid move"},
+		{name: "empty", fen: StartFEN, move: "", wantErr: "invalid move"},
+		{name: "bad FEN", fen: "not a fen", move: "e4", wantErr: "invalid FEN"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			san, next, err := ApplyMove(tt.fen, tt.move)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("ApplyMove(%q) = %q, %v; want error %q", tt.move, san, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyMove(%q): %v", tt.move, err)
+			}
+			if san != tt.san {
+				t.Errorf("ApplyMove(%q) SAN = %q, want %q", tt.move, san, tt.san)
+			}
+			if tt.next != "" && next != tt.next {
+				t.Errorf("ApplyMove(%q) FEN = %q, want %q", tt.move, next, tt.next)
+			}
+		})
+	}
+}
+
+// Every notation of a move leads to the same position.
+func TestApplyMoveNotationsAgree(t *testing.T) {
+	for _, moves := range [][]string{
+		{"Nf3", "g1f3", "Ng1-f3", "Ng1f3"},
+		{"e4", "e2e4", "e2-e4"},
+	} {
+		_, want, err := ApplyMove(StartFEN, moves[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, mv := range moves[1:] {
+			if _, got, err := ApplyMove(StartFEN, mv); err != nil || got != want {
+				t.Errorf("ApplyMove(%q) = %q, %v; want %q like %q", mv, got, err, want, moves[0])
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// ErrCanceled is returned when an operation is abandoned because the user navigated
+// away, cancelled it, or the application is shutting down.
+var ErrCanceled = errors.New("operation canceled")
+
+// canceledError wraps a context error so callers can match ErrCanceled as well as
+// context.Canceled or context.DeadlineExceeded.
+func canceledError(err error) error {
+	if err == nil || errors.Is(err, ErrCanceled) {
+		return err
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrCanceled, err)
+	}
+	return err
+}
+
+// checkContext returns a cancellation error once ctx is done; long loops call it per step.
+func checkContext(ctx context.Context) error {
+	return canceledError(ctx.Err())
+}
+
+// Startup ties m to the application context. Call it from the Wails OnStartup hook;
+// every query is cancelled once that context ends or Shutdown runs. It is a function
+// rather than a method so the frontend cannot call it through the bindings.
+func Startup(ctx context.Context, m *RepertoireManager) {
+	m.startup(ctx)
+}
+
+// Shutdown cancels every running operation of m and stops its analysis engine. Call
+// it from the Wails OnShutdown hook.
+func Shutdown(m *RepertoireManager) {
+	m.shutdown()
+}
+
+func (m *RepertoireManager) startup(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.appCtx = ctx
+	m.ctx, m.cancel = context.WithCancel(ctx)
+	m.posCtx, m.posCancel = nil, nil
+}
+
+func (m *RepertoireManager) shutdown() {
+	m.mu.Lock()
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.mu.Unlock()
+	m.StopEngine()
+}
+
+// emitEvent sends an event to the frontend. Events are dropped until Startup runs,
+// unless WithEvents receives them.
+func (m *RepertoireManager) emitEvent(name string, data any) {
+	m.mu.Lock()
+	emit, ctx := m.events, m.appCtx
+	m.mu.Unlock()
+	switch {
+	case emit != nil:
+		emit(name, data)
+	case ctx != nil:
+		runtime.EventsEmit(ctx, name, data)
+	}
+}
+
+// baseContext is the context for short queries; it ends when the application stops.
+func (m *RepertoireManager) baseContext() context.Context {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ctx == nil {
+		m.ctx, m.cancel = context.WithCancel(context.Background())
+	}
+	return m.ctx
+}
+
+// beginOperation starts a long-running operation (imports, tree walks, cache warming)
+// that CancelOperations can abort. The returned function must be called when it ends.
+func (m *RepertoireManager) beginOperation() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(m.baseContext())
+	m.mu.Lock()
+	m.nextOp++
+	id := m.nextOp
+	m.ops[id] = cancel
+	m.mu.Unlock()
+	return ctx, func() {
+		m.mu.Lock()
+		delete(m.ops, id)
+		m.mu.Unlock()
+		cancel()
+	}
+}
+
+// CancelOperations aborts every long-running operation in progress.
+func (m *RepertoireManager) CancelOperations() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, cancel := range m.ops {
+		cancel()
+		delete(m.ops, id)
+	}
+	if m.posCancel != nil {
+		m.posCancel()
+		m.posCtx, m.posCancel = nil, nil
+	}
+}
+
+// positionContext is cancelled as soon as the current position changes, so requests
+// for a position the user has left (such as explorer fetches) are abandoned.
+func (m *RepertoireManager) positionContext() context.Context {
+	base := m.baseContext()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.posCtx == nil || m.posCtx.Err() != nil {
+		m.posCtx, m.posCancel = context.WithCancel(base)
+	}
+	return m.posCtx
+}
+
+// setFEN moves to a new current position, cancelling requests made for the old one.
+func (m *RepertoireManager) setFEN(fen string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.setFENLocked(fen)
+}
+
+// setFENLocked is setFEN for callers that hold mu.
+func (m *RepertoireManager) setFENLocked(fen string) {
+	if fen != m.currentFEN && m.posCancel != nil {
+		m.posCancel()
+		m.posCtx, m.posCancel = nil, nil
+	}
+	m.currentFEN = fen
+	m.shownAt = m.now()
+}
+
+// selected returns the selected repertoire.
+func (m *RepertoireManager) selected() (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.selectedRep == 0 {
+		return 0, fmt.Errorf("no repertoire selected")
+	}
+	return m.selectedRep, nil
+}
+
+// currentPosition returns the selected repertoire and the current position. They are
+// read together so an operation works on one state while the user keeps browsing.
+func (m *RepertoireManager) currentPosition() (int64, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.selectedRep == 0 {
+		return 0, "", fmt.Errorf("no repertoire selected")
+	}
+	if m.currentFEN == "" {
+		return 0, "", fmt.Errorf("no current FEN set")
+	}
+	return m.selectedRep, m.currentFEN, nil
+}
@@ -0,0 +1,267 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// defaultCoverage applies to repertoires whose coverage was never set; the UI offers 50 to 200.
+const defaultCoverage = 50
+
+// coverageThreshold turns a coverage setting into the reach probability, 0 to 1, below
+// which lines are not prepared: coverage N covers every line reached in one game in N.
+func coverageThreshold(coverage float64) float64 {
+	if coverage <= 0 {
+		coverage = defaultCoverage
+	}
+	return 1 / coverage
+}
+
+func (m *RepertoireManager) repertoireThreshold(ctx context.Context, repID int64) (float64, error) {
+	var coverage float64
+	err := m.db.QueryRowContext(ctx, `SELECT coverage FROM repertoire WHERE id = ?`, repID).Scan(&coverage)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get repertoire coverage: %w", err)
+	}
+	return coverageThreshold(coverage), nil
+}
+
+// moveFrequency is how often a move is played in a position, and with what results,
+// per the explorer.
+type moveFrequency struct {
+	SAN                 string
+	FEN                 string // position after the move
+	White, Black, Draws int
+	Games               int
+	Frequency           float64 // share of the position's games, 0 to 1
+}
+
+// score is the move's result for color, counting draws as half a win.
+func (f moveFrequency) score(color string) float64 {
+	wins := f.White
+	if color == "black" {
+		wins = f.Black
+	}
+	return (float64(wins) + float64(f.Draws)/2) / float64(f.Games)
+}
+
+// moveFrequencies plays out every explorer move in fen. Moves the explorer lists but
+// that are not legal in fen are skipped.
+func (m *RepertoireManager) moveFrequencies(ctx context.Context, fen string, q ExplorerQuery) ([]moveFrequency, error) {
+	data, err := m.explorer.Explore(ctx, fen, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch explorer data: %w", err)
+	}
+	total := data.White + data.Black + data.Draws
+	out := make([]moveFrequency, 0, len(data.Moves))
+	for _, mv := range data.Moves {
+		games := mv.White + mv.Black + mv.Draws
+		if total == 0 || games == 0 {
+			continue
+		}
+		notation := mv.UCI
+		if notation == "" {
+			notation = mv.SAN
+		}
+		san, next, err := ApplyMove(fen, notation)
+		if err != nil {
+			continue
+		}
+		out = append(out, moveFrequency{
+			SAN:       san,
+			FEN:       next,
+			White:     mv.White,
+			Black:     mv.Black,
+			Draws:     mv.Draws,
+			Games:     games,
+			Frequency: float64(games) / float64(total),
+		})
+	}
+	return out, nil
+}
+
+// CoverageGap is an opponent reply the repertoire has no answer to.
+type CoverageGap struct {
+	FEN         string   `json:"fen"`         // position after the reply, where a move is needed
+	Move        string   `json:"move"`        // the opponent's reply, in SAN
+	Line        []string `json:"line"`        // moves from the start position, or the root FEN is reached from, to FEN
+	Probability float64  `json:"probability"` // chance of reaching FEN from that root, 0 to 1
+	Games       int      `json:"games"`       // explorer games with the reply
+}
+
+// FindCoverageGaps walks a repertoire from the start position and lists every opponent
+// reply, per the explorer, whose line is reached at least as often as the repertoire's
+// coverage threshold but that has no prepared answer. A line's probability is the
+// product of the opponent's move frequencies along it, our own moves counting as
+// certain; a position reached by several lines takes its most likely one. Recorded
+// roots, such as the [FEN] root of an imported game, are walked from as well, as if the
+// game started there; positions cut off from every root are not. Replies that
+// transpose into a prepared position are covered. Gaps are ranked most likely first.
+func (m *RepertoireManager) FindCoverageGaps(repID int64) ([]CoverageGap, error) {
+	ctx, done := m.beginOperation()
+	defer done()
+
+	color, err := m.repertoireColor(ctx, repID)
+	if err != nil {
+		return nil, canceledError(err)
+	}
+	threshold, err := m.repertoireThreshold(ctx, repID)
+	if err != nil {
+		return nil, canceledError(err)
+	}
+	q, err := m.repertoireExplorerQuery(ctx, repID)
+	if err != nil {
+		return nil, canceledError(err)
+	}
+	g, err := loadGraph(ctx, m.db, repID)
+	if err != nil {
+		return nil, canceledError(err)
+	}
+	g.withoutDeprecated()
+
+	w := newGraphWalk(g)
+	gaps := map[string]*CoverageGap{}
+	for w.more() {
+		if err := checkContext(ctx); err != nil {
+			return nil, err
+		}
+		fen, p := w.pop()
+		if sideToMove(fen) == color {
+			for _, e := range g.children[fen] {
+				w.reach(fen, e.ChildFEN, g.displayFEN(e.ChildFEN), e.MoveSAN, p)
+			}
+			continue
+		}
+		if p < threshold {
+			continue
+		}
+		replies, err := m.moveFrequencies(ctx, w.display[fen], q)
+		if err != nil {
+			return nil, canceledError(err)
+		}
+		for _, r := range replies {
+			reach := p * r.Frequency
+			if reach < threshold {
+				continue
+			}
+			child := positionKey(r.FEN)
+			if len(g.children[child]) > 0 {
+				w.reach(fen, child, g.displayFEN(child), r.SAN, reach)
+				continue
+			}
+			if gap, ok := gaps[child]; ok && gap.Probability >= reach {
+				continue
+			}
+			gaps[child] = &CoverageGap{
+				FEN:         r.FEN,
+				Move:        r.SAN,
+				Line:        append(w.line(fen), r.SAN),
+				Probability: reach,
+				Games:       r.Games,
+			}
+		}
+	}
+
+	out := make([]CoverageGap, 0, len(gaps))
+	for _, gap := range gaps {
+		out = append(out, *gap)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Probability != out[j].Probability {
+			return out[i].Probability > out[j].Probability
+		}
+		return out[i].Games > out[j].Games
+	})
+	return out, nil
+}
+
+// reachWalk visits positions in order of decreasing reach probability, so each
+// position is expanded once, with the most likely line leading to it.
+type reachWalk struct {
+	prob    map[string]float64
+	display map[string]string // full FEN of each position, as reached
+	via     map[string]reachStep
+	done    map[string]bool
+	queue   []string
+}
+
+// reachStep is the move a position was best reached with.
+type reachStep struct {
+	parent string
+	move   string
+}
+
+func newReachWalk(root, rootFEN string) *reachWalk {
+	return &reachWalk{
+		prob:    map[string]float64{root: 1},
+		display: map[string]string{root: rootFEN},
+		via:     map[string]reachStep{},
+		done:    map[string]bool{},
+		queue:   []string{root},
+	}
+}
+
+// newGraphWalk starts a reachWalk at the start position and the recorded roots of g,
+// each certain to be reached. Positions cut off from them are never walked.
+func newGraphWalk(g *repGraph) *reachWalk {
+	roots := g.roots()
+	w := newReachWalk(roots[0], g.displayFEN(roots[0]))
+	for _, root := range roots[1:] {
+		w.prob[root] = 1
+		w.display[root] = g.displayFEN(root)
+		w.queue = append(w.queue, root)
+	}
+	return w
+}
+
+func (w *reachWalk) more() bool {
+	return len(w.queue) > 0
+}
+
+// pop returns the most likely position not yet expanded.
+func (w *reachWalk) pop() (string, float64) {
+	best := 0
+	for i, fen := range w.queue {
+		if w.prob[fen] > w.prob[w.queue[best]] {
+			best = i
+		}
+	}
+	fen := w.queue[best]
+	w.queue = slices.Delete(w.queue, best, best+1)
+	w.done[fen] = true
+	return fen, w.prob[fen]
+}
+
+// reach records that child is reached from parent with move at probability p.
+func (w *reachWalk) reach(parent, child, display, move string, p float64) {
+	if w.done[child] {
+		return
+	}
+	old, seen := w.prob[child]
+	if seen && old >= p {
+		return
+	}
+	if !seen {
+		w.queue = append(w.queue, child)
+	}
+	w.prob[child] = p
+	w.display[child] = display
+	w.via[child] = reachStep{parent: parent, move: move}
+}
+
+// line returns the moves of the most likely line to fen.
+func (w *reachWalk) line(fen string) []string {
+	var moves []string
+	for {
+		step, ok := w.via[fen]
+		if !ok {
+			break
+		}
+		moves = append(moves, step.move)
+		fen = step.parent
+	}
+	slices.Reverse(moves)
+	return moves
+}
@@ -0,0 +1,42 @@
+package backend
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestFindCoverageGapsIgnoresOrphans(t *testing.T) {
+	fe := NewFakeExplorer()
+	m, repID := newTestManager(t, WithExplorer(fe))
+	addLine(t, m, "e4", "e5", "Nf3")
+	addLine(t, m, "d4", "d5", "c4")
+	e4 := playLine(t, "e4")
+	qg := playLine(t, "d4", "d5", "c4")
+	fe.Responses[e4[0]] = explorerMoves(map[string]int{"e5": 50, "c5": 50})
+	fe.Responses[qg[0]] = explorerMoves(map[string]int{"d5": 100})
+	fe.Responses[qg[2]] = explorerMoves(map[string]int{"dxc4": 100})
+
+	// Cut 1.d4 alone, leaving 1...d5 2.c4 without a way to reach it.
+	_, err := m.db.Exec(`DELETE FROM edges WHERE rep_id = ? AND parent_fen = ? AND move = 'd4'`, repID, startKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gaps, err := m.FindCoverageGaps(repID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gaps) != 1 {
+		t.Fatalf("gaps = %+v, want only 1...c5", gaps)
+	}
+	gap := gaps[0]
+	if gap.Move != "c5" || !slices.Equal(gap.Line, []string{"e4", "c5"}) || math.Abs(gap.Probability-0.5) > 1e-9 || gap.Games != 50 {
+		t.Errorf("gap = %+v, want 1.e4 c5 at 0.5 in 50 games", gap)
+	}
+	for _, fen := range fe.Calls {
+		if fen == qg[0] || fen == qg[2] {
+			t.Errorf("explorer asked about %s, which no root reaches", fen)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package backend
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Edge kinds, stored in edges.kind.
+const (
+	EdgePrimary    = "primary"    // the move the repertoire teaches
+	EdgeAlternate  = "alternate"  // also acceptable, graded more softly
+	EdgeOpponent   = "opponent"   // an opponent reply to prepare for
+	EdgeDeprecated = "deprecated" // kept for reference but no longer played or trained
+)
+
+// newEdgeKind returns the kind a move added from parentFEN starts with: opponent
+// when the other side is to move, otherwise primary unless the position already has one.
+func newEdgeKind(ctx context.Context, q execer, repID int64, color, parentFEN string) (string, error) {
+	if sideToMove(parentFEN) != color {
+		return EdgeOpponent, nil
+	}
+	var n int
+	err := q.QueryRowContext(ctx,
+		`SELECT COUNT(1) FROM edges WHERE rep_id = ? AND parent_fen = ? AND kind = ?`,
+		repID, parentFEN, EdgePrimary).Scan(&n)
+	if err != nil {
+		return "", err
+	}
+	if n > 0 {
+		return EdgeAlternate, nil
+	}
+	return EdgePrimary, nil
+}
+
+// ensurePrimary makes the oldest alternate from parentFEN primary if the position lost
+// its primary move, so every position with playable moves keeps one to teach.
+func ensurePrimary(ctx context.Context, tx *sql.Tx, repID int64, parentFEN string) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE edges SET kind = ?
+		 WHERE rowid = (SELECT MIN(rowid) FROM edges WHERE rep_id = ? AND parent_fen = ? AND kind = ?)
+		   AND NOT EXISTS (SELECT 1 FROM edges WHERE rep_id = ? AND parent_fen = ? AND kind = ?)`,
+		EdgePrimary, repID, parentFEN, EdgeAlternate, repID, parentFEN, EdgePrimary)
+	return err
+}
+
+// withoutDeprecated drops deprecated moves from the graph's children, leaving the
+// lines that are still played.
+func (g *repGraph) withoutDeprecated() *repGraph {
+	for fen, edges := range g.children {
+		kept := edges[:0:0]
+		for _, e := range edges {
+			if e.Kind != EdgeDeprecated {
+				kept = append(kept, e)
+			}
+		}
+		g.children[fen] = kept
+	}
+	return g
+}
+
+// ListMoves returns the moves stored from the current position with their kinds.
+func (m *RepertoireManager) ListMoves() ([]Edge, error) {
+	repID, fen, err := m.currentPosition()
+	if err != nil {
+		return nil, err
+	}
+	g, err := loadGraph(m.baseContext(), m.db, repID)
+	if err != nil {
+		return nil, err
+	}
+	moves := g.children[positionKey(fen)]
+	if moves == nil {
+		moves = []Edge{}
+	}
+	return moves, nil
+}
+
+// SetEdgeKind marks moveSAN from the current position as primary, alternate, opponent
+// or deprecated. Primary, alternate and deprecated apply to the repertoire side's moves,
+// opponent and deprecated to the other side's. Making a move primary turns the previous
+// primary move into an alternate; making the primary move an alternate promotes the
+// oldest other alternate in its place, and fails if there is none.
+func (m *RepertoireManager) SetEdgeKind(moveSAN, kind string) error {
+	repID, fen, err := m.currentPosition()
+	if err != nil {
+		return err
+	}
+	ctx, parentKey, moveSAN := m.baseContext(), positionKey(fen), canonicalSAN(fen, moveSAN)
+
+	color, err := m.repertoireColor(ctx, repID)
+	if err != nil {
+		return err
+	}
+	own := sideToMove(fen) == color
+	switch kind {
+	case EdgePrimary, EdgeAlternate:
+		if !own {
+			return fmt.Errorf("%s moves must be for %s", kind, color)
+		}
+	case EdgeOpponent:
+		if own {
+			return fmt.Errorf("opponent moves must be for %s", oppositeColor(color))
+		}
+	case EdgeDeprecated:
+	default:
+		return fmt.Errorf("unknown move kind %q", kind)
+	}
+
+	return m.inTx(ctx, func(tx *sql.Tx) error {
+		var current string
+		err := tx.QueryRowContext(ctx,
+			`SELECT kind FROM edges WHERE rep_id = ? AND parent_fen = ? AND move = ?`,
+			repID, parentKey, moveSAN).Scan(&current)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("edge not found")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load move: %w", err)
+		}
+		if kind == EdgeAlternate && current == EdgePrimary {
+			res, err := tx.ExecContext(ctx,
+				`UPDATE edges SET kind = ?
+				 WHERE rowid = (SELECT MIN(rowid) FROM edges WHERE rep_id = ? AND parent_fen = ? AND kind = ?)`,
+				EdgePrimary, repID, parentKey, EdgeAlternate)
+			if err != nil {
+				return fmt.Errorf("failed to update moves: %w", err)
+			}
+			if n, _ := res.RowsAffected(); n == 0 {
+				return fmt.Errorf("%s is the only move taught here; make another move primary instead", moveSAN)
+			}
+		}
+		if kind == EdgePrimary {
+			_, err := tx.ExecContext(ctx,
+				`UPDATE edges SET kind = ? WHERE rep_id = ? AND parent_fen = ? AND kind = ? AND move <> ?`,
+				EdgeAlternate, repID, parentKey, EdgePrimary, moveSAN)
+			if err != nil {
+				return fmt.Errorf("failed to update moves: %w", err)
+			}
+		}
+		_, err = tx.ExecContext(ctx,
+			`UPDATE edges SET kind = ? WHERE rep_id = ? AND parent_fen = ? AND move = ?`,
+			kind, repID, parentKey, moveSAN)
+		if err != nil {
+			return fmt.Errorf("failed to update move: %w", err)
+		}
+		return ensurePrimary(ctx, tx, repID, parentKey)
+	})
+}
@@ -0,0 +1,87 @@
+package backend
+
+import (
+	"testing"
+)
+
+// moveKinds returns the kinds of the moves stored from the start position.
+func moveKinds(t *testing.T, m *RepertoireManager) map[string]string {
+	t.Helper()
+	m.SetCurrentFEN(StartFEN)
+	moves, err := m.ListMoves()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := map[string]string{}
+	for _, e := range moves {
+		kinds[e.MoveSAN] = e.Kind
+	}
+	return kinds
+}
+
+func TestSetEdgeKind(t *testing.T) {
+	m, _ := newTestManager(t)
+	addLine(t, m, "e4")
+	addLine(t, m, "d4")
+	addLine(t, m, "c4")
+
+	steps := []struct {
+		move, kind string
+		want       map[string]string
+		wantErr    bool
+	}{
+		{"d4", EdgePrimary, map[string]string{"e4": EdgeAlternate, "d4": EdgePrimary, "c4": EdgeAlternate}, false},
+		// Demoting the primary move promotes the oldest other alternate.
+		{"d4", EdgeAlternate, map[string]string{"e4": EdgePrimary, "d4": EdgeAlternate, "c4": EdgeAlternate}, false},
+		{"e4", EdgeDeprecated, map[string]string{"e4": EdgeDeprecated, "d4": EdgePrimary, "c4": EdgeAlternate}, false},
+		{"c4", EdgeDeprecated, map[string]string{"e4": EdgeDeprecated, "d4": EdgePrimary, "c4": EdgeDeprecated}, false},
+		// d4 is the only move left to teach, so it cannot become an alternate.
+		{"d4", EdgeAlternate, map[string]string{"e4": EdgeDeprecated, "d4": EdgePrimary, "c4": EdgeDeprecated}, true},
+		{"e4", EdgeAlternate, map[string]string{"e4": EdgeAlternate, "d4": EdgePrimary, "c4": EdgeDeprecated}, false},
+		{"d4", EdgeOpponent, map[string]string{"e4": EdgeAlternate, "d4": EdgePrimary, "c4": EdgeDeprecated}, true},
+		{"d4", "favourite", map[string]string{"e4": EdgeAlternate, "d4": EdgePrimary, "c4": EdgeDeprecated}, true},
+		{"Nf3", EdgePrimary, map[string]string{"e4": EdgeAlternate, "d4": EdgePrimary, "c4": EdgeDeprecated}, true},
+		{"g1f3", EdgePrimary, map[string]string{"e4": EdgeAlternate, "d4": EdgePrimary, "c4": EdgeDeprecated}, true},
+		{"e2e4", EdgePrimary, map[string]string{"e4": EdgePrimary, "d4": EdgeAlternate, "c4": EdgeDeprecated}, false},
+	}
+	for i, step := range steps {
+		m.SetCurrentFEN(StartFEN)
+		err := m.SetEdgeKind(step.move, step.kind)
+		if (err != nil) != step.wantErr {
+			t.Fatalf("step %d: SetEdgeKind(%s, %s) = %v, want error %v", i+1, step.move, step.kind, err, step.wantErr)
+		}
+		got := moveKinds(t, m)
+		for move, kind := range step.want {
+			if got[move] != kind {
+				t.Errorf("step %d: after SetEdgeKind(%s, %s) %s is %s, want %s",
+					i+1, step.move, step.kind, move, got[move], kind)
+			}
+		}
+	}
+}
+
+func TestSetEdgeKindOpponentMoves(t *testing.T) {
+	m, _ := newTestManager(t)
+	addLine(t, m, "e4", "e5")
+	addLine(t, m, "e4", "c5")
+	m.SetCurrentFEN(playLine(t, "e4")[0])
+	if err := m.SetEdgeKind("e5", EdgePrimary); err == nil {
+		t.Error("an opponent reply was made primary")
+	}
+	if err := m.SetEdgeKind("c5", EdgeDeprecated); err != nil {
+		t.Fatal(err)
+	}
+	moves, err := m.ListMoves()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range moves {
+		want := EdgeOpponent
+		if e.MoveSAN == "c5" {
+			want = EdgeDeprecated
+		}
+		if e.Kind != want {
+			t.Errorf("%s is %s, want %s", e.MoveSAN, e.Kind, want)
+		}
+	}
+}
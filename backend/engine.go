@@ -0,0 +1,565 @@
+package backend
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	engineStartTimeout = 10 * time.Second // for the handshake after launching
+	engineReadyTimeout = 10 * time.Second // for "readyok" after setting options
+	engineStopTimeout  = 5 * time.Second  // for "bestmove" after "stop", and for exiting after "quit"
+
+	// engineUpdateInterval spaces out analysis updates; engines print many lines a second.
+	engineUpdateInterval = 100 * time.Millisecond
+)
+
+// errEngineExited is returned when the engine process ends while a reply is awaited.
+var errEngineExited = errors.New("engine exited")
+
+// EngineConfig says how to launch a local UCI engine.
+type EngineConfig struct {
+	Path    string            `json:"path"`    // engine binary, e.g. stockfish
+	Args    []string          `json:"args"`    // command line arguments, usually none
+	Options map[string]string `json:"options"` // UCI options set after launching, e.g. Threads or Hash
+}
+
+// EngineProcess is a running UCI engine: commands go in, output comes back line by line.
+type EngineProcess interface {
+	Send(cmd string) error
+	Lines() <-chan string // closed when the engine exits
+	Close() error         // ends the process if it has not exited
+}
+
+// EngineLauncher starts the engine a config describes.
+type EngineLauncher func(cfg EngineConfig) (EngineProcess, error)
+
+// LaunchEngine runs cfg.Path as a child process talking UCI over stdin and stdout.
+func LaunchEngine(cfg EngineConfig) (EngineProcess, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("no engine path set")
+	}
+	cmd := exec.Command(cfg.Path, cfg.Args...)
+	hideWindow(cmd)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open engine input: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open engine output: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start engine: %w", err)
+	}
+	p := &execEngine{
+		cmd:    cmd,
+		stdin:  stdin,
+		lines:  make(chan string, 256),
+		exited: make(chan struct{}),
+	}
+	go p.read(stdout)
+	return p, nil
+}
+
+// execEngine is an engine running as a child process.
+type execEngine struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	lines  chan string
+	exited chan struct{} // closed once the process has been waited for
+}
+
+func (p *execEngine) read(stdout io.Reader) {
+	sc := bufio.NewScanner(stdout)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		p.lines <- sc.Text()
+	}
+	close(p.lines)
+	p.cmd.Wait()
+	close(p.exited)
+}
+
+func (p *execEngine) Send(cmd string) error {
+	if _, err := io.WriteString(p.stdin, cmd+"\n"); err != nil {
+		return fmt.Errorf("failed to send %q to engine: %w", cmd, err)
+	}
+	return nil
+}
+
+func (p *execEngine) Lines() <-chan string {
+	return p.lines
+}
+
+// Close closes the engine's input, which UCI engines take as "quit", and kills the
+// process if it is still running after engineStopTimeout.
+func (p *execEngine) Close() error {
+	p.stdin.Close()
+	go func() {
+		// Nobody reads the output any more; keep the pipe from filling up.
+		for range p.lines {
+		}
+	}()
+	select {
+	case <-p.exited:
+		return nil
+	case <-time.After(engineStopTimeout):
+	}
+	if err := p.cmd.Process.Kill(); err != nil {
+		return fmt.Errorf("failed to stop engine: %w", err)
+	}
+	<-p.exited
+	return nil
+}
+
+// EngineOption is an option an engine declares in its UCI handshake.
+type EngineOption struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"` // check, spin, combo, button or string
+	Default string   `json:"default"`
+	Min     int      `json:"min"`   // lowest value of a spin option
+	Max     int      `json:"max"`   // highest value of a spin option
+	Vars    []string `json:"vars"`  // choices of a combo option
+	Value   string   `json:"value"` // as last set, the default until then
+}
+
+// Engine speaks UCI to an engine process. Only one command runs at a time.
+type Engine struct {
+	mu      sync.Mutex // held while a command runs
+	proc    EngineProcess
+	name    string
+	author  string
+	optMu   sync.Mutex // also held to change options, so they can be read during a search
+	options []EngineOption
+}
+
+// NewEngine performs the UCI handshake with proc, sets the given options and waits
+// until the engine is ready. The process is closed if any of that fails.
+func NewEngine(ctx context.Context, proc EngineProcess, options map[string]string) (*Engine, error) {
+	e := &Engine{proc: proc}
+	err := func() error {
+		hctx, cancel := context.WithTimeout(ctx, engineStartTimeout)
+		defer cancel()
+		if err := proc.Send("uci"); err != nil {
+			return err
+		}
+		for {
+			line, err := e.readLine(hctx)
+			if err != nil {
+				return fmt.Errorf("no UCI handshake from engine: %w", err)
+			}
+			switch {
+			case line == "uciok":
+				return nil
+			case strings.HasPrefix(line, "id name "):
+				e.name = strings.TrimSpace(strings.TrimPrefix(line, "id name "))
+			case strings.HasPrefix(line, "id author "):
+				e.author = strings.TrimSpace(strings.TrimPrefix(line, "id author "))
+			case strings.HasPrefix(line, "option "):
+				if opt, ok := parseEngineOption(line); ok {
+					e.options = append(e.options, opt)
+				}
+			}
+		}
+	}()
+	if err == nil {
+		names := make([]string, 0, len(options))
+		for name := range options {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err = e.setOption(name, options[name]); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = e.sync(ctx)
+	}
+	if err != nil {
+		proc.Close()
+		return nil, canceledError(err)
+	}
+	return e, nil
+}
+
+// Name is the engine's name as it reports it.
+func (e *Engine) Name() string {
+	return e.name
+}
+
+// Author is the engine's author as it reports it.
+func (e *Engine) Author() string {
+	return e.author
+}
+
+// Options returns the options the engine declares, with their current values.
+func (e *Engine) Options() []EngineOption {
+	e.optMu.Lock()
+	defer e.optMu.Unlock()
+	return append([]EngineOption(nil), e.options...)
+}
+
+// SetOption sets one of the engine's options and waits until the engine is ready again.
+// Option names are matched ignoring case; buttons ignore value.
+func (e *Engine) SetOption(ctx context.Context, name, value string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.setOption(name, value); err != nil {
+		return err
+	}
+	return canceledError(e.sync(ctx))
+}
+
+func (e *Engine) setOption(name, value string) error {
+	i := e.option(name)
+	if i < 0 {
+		return fmt.Errorf("unknown engine option %q", name)
+	}
+	opt := &e.options[i]
+	switch opt.Type {
+	case "button":
+		return e.proc.Send("setoption name " + opt.Name)
+	case "spin":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < opt.Min || n > opt.Max {
+			return fmt.Errorf("engine option %s must be a number from %d to %d", opt.Name, opt.Min, opt.Max)
+		}
+	case "check":
+		if value != "true" && value != "false" {
+			return fmt.Errorf("engine option %s must be true or false", opt.Name)
+		}
+	}
+	if err := e.proc.Send("setoption name " + opt.Name + " value " + value); err != nil {
+		return err
+	}
+	e.optMu.Lock()
+	opt.Value = value
+	e.optMu.Unlock()
+	return nil
+}
+
+// option returns the index of the named option, or -1.
+func (e *Engine) option(name string) int {
+	for i, opt := range e.options {
+		if strings.EqualFold(opt.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sync sends "isready" and waits for "readyok", skipping whatever comes before it.
+func (e *Engine) sync(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, engineReadyTimeout)
+	defer cancel()
+	if err := e.proc.Send("isready"); err != nil {
+		return err
+	}
+	for {
+		line, err := e.readLine(ctx)
+		if err != nil {
+			return fmt.Errorf("engine not ready: %w", err)
+		}
+		if line == "readyok" {
+			return nil
+		}
+	}
+}
+
+func (e *Engine) readLine(ctx context.Context) (string, error) {
+	select {
+	case line, ok := <-e.proc.Lines():
+		if !ok {
+			return "", errEngineExited
+		}
+		return strings.TrimSpace(line), nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Close asks the engine to quit and ends its process.
+func (e *Engine) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.proc.Send("quit")
+	return e.proc.Close()
+}
+
+// AnalysisLimits bounds an engine search. With neither a depth nor a time the search
+// runs until it is stopped.
+type AnalysisLimits struct {
+	Lines    int `json:"lines"`    // principal variations to report, 1 if zero
+	Depth    int `json:"depth"`    // plies to search to, no limit if zero
+	MoveTime int `json:"moveTime"` // milliseconds to search for, no limit if zero
+}
+
+// PVLine is one principal variation of an analysis.
+type PVLine struct {
+	Rank  int      `json:"rank"` // 1 for the best line
+	Depth int      `json:"depth"`
+	Score int      `json:"score"` // centipawns from White's point of view
+	Mate  int      `json:"mate"`  // moves to mate, positive when White mates; 0 when no mate is seen
+	Moves []string `json:"moves"` // SAN
+	UCI   []string `json:"uci"`
+}
+
+// EngineAnalysis is the state of an engine search, sent as it progresses.
+type EngineAnalysis struct {
+	FEN      string   `json:"fen"`
+	Depth    int      `json:"depth"` // of the best line
+	Nodes    int64    `json:"nodes"`
+	TimeMs   int      `json:"timeMs"`
+	Lines    []PVLine `json:"lines"`    // best first
+	BestMove string   `json:"bestMove"` // SAN, once done
+	Done     bool     `json:"done"`
+	Error    string   `json:"error"` // why the search failed, on the last update
+}
+
+// Analyze searches fen within limits, calling update with the analysis so far as the
+// engine reports progress, at most every engineUpdateInterval. When ctx ends the
+// search is stopped and the analysis so far returned with a cancellation error.
+func (e *Engine) Analyze(ctx context.Context, fen string, limits AnalysisLimits, update func(EngineAnalysis)) (EngineAnalysis, error) {
+	if _, err := positionFromFEN(fen); err != nil {
+		return EngineAnalysis{}, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	lines := max(limits.Lines, 1)
+	if i := e.option("MultiPV"); i >= 0 {
+		lines = min(lines, max(e.options[i].Max, 1))
+		if err := e.setOption("MultiPV", strconv.Itoa(lines)); err != nil {
+			return EngineAnalysis{}, err
+		}
+	} else {
+		lines = 1
+	}
+	if err := e.sync(ctx); err != nil {
+		return EngineAnalysis{}, canceledError(err)
+	}
+
+	goCmd := "go"
+	if limits.Depth > 0 {
+		goCmd += " depth " + strconv.Itoa(limits.Depth)
+	}
+	if limits.MoveTime > 0 {
+		goCmd += " movetime " + strconv.Itoa(limits.MoveTime)
+	}
+	if limits.Depth <= 0 && limits.MoveTime <= 0 {
+		goCmd += " infinite"
+	}
+	for _, cmd := range []string{"position fen " + fen, goCmd} {
+		if err := e.proc.Send(cmd); err != nil {
+			return EngineAnalysis{}, err
+		}
+	}
+
+	a := EngineAnalysis{FEN: fen, Lines: []PVLine{}}
+	pvs := make([]*PVLine, lines)
+	var sent time.Time
+	readCtx := ctx
+	for {
+		line, err := e.readLine(readCtx)
+		if err != nil && readCtx == ctx && ctx.Err() != nil {
+			// Stop the search and wait for its bestmove so the engine is idle again.
+			if err := e.proc.Send("stop"); err != nil {
+				return a, err
+			}
+			var cancel context.CancelFunc
+			readCtx, cancel = context.WithTimeout(context.Background(), engineStopTimeout)
+			defer cancel()
+			continue
+		}
+		if err != nil {
+			return a, fmt.Errorf("engine did not finish its search: %w", err)
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "info":
+			info, ok := parseEngineInfo(fields[1:])
+			if !ok {
+				continue
+			}
+			if info.nodes > 0 {
+				a.Nodes, a.TimeMs = info.nodes, info.time
+			}
+			if info.pv == nil || info.multipv > lines {
+				continue
+			}
+			pv := pvLine(fen, info)
+			pvs[info.multipv-1] = &pv
+			a.Lines = a.Lines[:0]
+			for _, pv := range pvs {
+				if pv != nil {
+					a.Lines = append(a.Lines, *pv)
+				}
+			}
+			if pvs[0] != nil {
+				a.Depth = pvs[0].Depth
+			}
+			if update != nil && time.Since(sent) >= engineUpdateInterval {
+				sent = time.Now()
+				update(snapshotAnalysis(a))
+			}
+		case "bestmove":
+			if len(fields) > 1 {
+				if san, _, err := ApplyMove(fen, fields[1]); err == nil {
+					a.BestMove = san
+				}
+			}
+			a.Done = true
+			return snapshotAnalysis(a), canceledError(ctx.Err())
+		}
+	}
+}
+
+// snapshotAnalysis copies a so the caller can keep it while the search goes on.
+func snapshotAnalysis(a EngineAnalysis) EngineAnalysis {
+	a.Lines = append([]PVLine{}, a.Lines...)
+	return a
+}
+
+// engineInfo holds the fields of an "info" line that analysis uses.
+type engineInfo struct {
+	depth, multipv int
+	cp, mate       int
+	nodes          int64
+	time           int
+	bound          bool // the score is only a lower or upper bound
+	pv             []string
+}
+
+// parseEngineInfo reads the fields after "info". Lines without a depth, such as
+// "info string", are not reported.
+func parseEngineInfo(fields []string) (engineInfo, bool) {
+	info := engineInfo{multipv: 1}
+	num := func(i int) int {
+		if i >= len(fields) {
+			return 0
+		}
+		n, _ := strconv.Atoi(fields[i])
+		return n
+	}
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "string":
+			return engineInfo{}, false
+		case "depth":
+			i++
+			info.depth = num(i)
+		case "multipv":
+			i++
+			info.multipv = max(num(i), 1)
+		case "score":
+			if i+2 < len(fields) {
+				if fields[i+1] == "mate" {
+					info.mate = num(i + 2)
+				} else {
+					info.cp = num(i + 2)
+				}
+			}
+			i += 2
+		case "lowerbound", "upperbound":
+			info.bound = true
+		case "nodes":
+			i++
+			if i < len(fields) {
+				info.nodes, _ = strconv.ParseInt(fields[i], 10, 64)
+			}
+		case "time":
+			i++
+			info.time = num(i)
+		case "pv":
+			info.pv = fields[i+1:]
+			i = len(fields)
+		case "refutation", "currline":
+			i = len(fields)
+		default:
+			// Every other field, such as seldepth, nps or currmove, takes one value.
+			i++
+		}
+	}
+	if info.bound {
+		// Bounded scores come from failed iterations; wait for the exact one.
+		info.pv = nil
+	}
+	return info, info.depth > 0
+}
+
+// pvLine turns a reported variation into SAN, from White's point of view. The moves
+// stop at the first one that is not legal.
+func pvLine(fen string, info engineInfo) PVLine {
+	pv := PVLine{Rank: info.multipv, Depth: info.depth, Score: info.cp, Mate: info.mate, Moves: []string{}, UCI: []string{}}
+	if sideToMove(fen) == "black" {
+		pv.Score, pv.Mate = -pv.Score, -pv.Mate
+	}
+	pos := fen
+	for _, uci := range info.pv {
+		san, next, err := ApplyMove(pos, uci)
+		if err != nil {
+			break
+		}
+		pv.Moves = append(pv.Moves, san)
+		pv.UCI = append(pv.UCI, uci)
+		pos = next
+	}
+	return pv
+}
+
+// parseEngineOption reads an "option name ... type ..." line. Names and values may
+// contain spaces, so each runs up to the next keyword.
+func parseEngineOption(line string) (EngineOption, bool) {
+	opt := EngineOption{Vars: []string{}}
+	var key string
+	var words []string
+	flush := func() {
+		value := strings.Join(words, " ")
+		switch key {
+		case "name":
+			opt.Name = value
+		case "type":
+			opt.Type = value
+		case "default":
+			opt.Default = value
+		case "min":
+			opt.Min, _ = strconv.Atoi(value)
+		case "max":
+			opt.Max, _ = strconv.Atoi(value)
+		case "var":
+			opt.Vars = append(opt.Vars, value)
+		}
+		words = words[:0]
+	}
+	for _, word := range strings.Fields(line)[1:] {
+		switch word {
+		case "name", "type", "default", "min", "max", "var":
+			flush()
+			key = word
+			continue
+		}
+		words = append(words, word)
+	}
+	flush()
+	if opt.Default == "<empty>" {
+		opt.Default = ""
+	}
+	opt.Value = opt.Default
+	return opt, opt.Name != "" && opt.Type != ""
+}
@@ -0,0 +1,120 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// FakeEngine is a scripted UCI engine for tests and development without an engine
+// binary. It answers the handshake with its declared options and every "go" with the
+// canned info lines for the position, followed by a bestmove taken from the last
+// best line. A "go infinite" search holds its bestmove until "stop".
+type FakeEngine struct {
+	mu       sync.Mutex
+	Name     string
+	Options  []string            // "option ..." lines sent in the handshake
+	Analysis map[string][]string // "info ..." lines per FEN
+	Err      error               // returned from Launch when set
+	Commands []string            // received, in order
+
+	lines    chan string
+	fen      string
+	bestmove string // held back by an infinite search
+	closed   bool
+}
+
+// NewFakeEngine returns a FakeEngine declaring a MultiPV option and no analysis.
+func NewFakeEngine() *FakeEngine {
+	return &FakeEngine{
+		Name:     "Fake Engine",
+		Options:  []string{"option name MultiPV type spin default 1 min 1 max 5"},
+		Analysis: make(map[string][]string),
+	}
+}
+
+// Launch starts a fresh session of the fake; use it as the manager's EngineLauncher.
+func (f *FakeEngine) Launch(cfg EngineConfig) (EngineProcess, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return nil, fmt.Errorf("fake engine: %w", f.Err)
+	}
+	f.lines = make(chan string, 1024)
+	f.fen, f.bestmove, f.closed = StartFEN, "", false
+	return f, nil
+}
+
+// Send records cmd and queues the scripted reply.
+func (f *FakeEngine) Send(cmd string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return fmt.Errorf("fake engine: %w", errEngineExited)
+	}
+	f.Commands = append(f.Commands, cmd)
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return nil
+	}
+	switch fields[0] {
+	case "uci":
+		f.lines <- "id name " + f.Name
+		for _, opt := range f.Options {
+			f.lines <- opt
+		}
+		f.lines <- "uciok"
+	case "isready":
+		f.lines <- "readyok"
+	case "position":
+		if len(fields) > 2 && fields[1] == "fen" {
+			f.fen = strings.Join(fields[2:], " ")
+		}
+	case "go":
+		best := "(none)"
+		for _, line := range f.Analysis[f.fen] {
+			f.lines <- line
+			if strings.Contains(line, " multipv ") && !strings.Contains(line, " multipv 1 ") {
+				continue
+			}
+			if i := strings.Index(line, " pv "); i >= 0 {
+				best = strings.Fields(line[i+4:])[0]
+			}
+		}
+		if strings.Contains(cmd, "infinite") {
+			f.bestmove = best
+		} else {
+			f.lines <- "bestmove " + best
+		}
+	case "stop":
+		if f.bestmove != "" {
+			f.lines <- "bestmove " + f.bestmove
+			f.bestmove = ""
+		}
+	case "quit":
+		f.close()
+	}
+	return nil
+}
+
+// Lines returns the fake's output.
+func (f *FakeEngine) Lines() <-chan string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lines
+}
+
+// Close ends the session.
+func (f *FakeEngine) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.close()
+	return nil
+}
+
+func (f *FakeEngine) close() {
+	if !f.closed {
+		f.closed = true
+		close(f.lines)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !windows
+
+package backend
+
+import "os/exec"
+
+func hideWindow(cmd *exec.Cmd) {}
@@ -0,0 +1,248 @@
+package backend
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newEngineTestManager returns a manager whose engine is fe and whose frontend
+// events arrive on the returned channel.
+func newEngineTestManager(t *testing.T, fe *FakeEngine) (*RepertoireManager, <-chan EngineAnalysis) {
+	t.Helper()
+	db, err := Open("file:" + filepath.Join(t.TempDir(), "repertoire.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan EngineAnalysis, 100)
+	m := NewRepertoireManager(db.SQL,
+		WithExplorer(NewFakeExplorer()),
+		WithEngineLauncher(fe.Launch),
+		WithEvents(func(name string, data any) {
+			if name == EngineAnalysisEvent {
+				events <- data.(EngineAnalysis)
+			}
+		}))
+	t.Cleanup(func() {
+		m.shutdown()
+		db.Close()
+	})
+	return m, events
+}
+
+// waitDone collects analysis events up to and including the final one.
+func waitDone(t *testing.T, events <-chan EngineAnalysis) (updates []EngineAnalysis, final EngineAnalysis) {
+	t.Helper()
+	for {
+		select {
+		case a := <-events:
+			if a.Done {
+				return updates, a
+			}
+			updates = append(updates, a)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no final analysis event")
+		}
+	}
+}
+
+func TestStartEngineHandshake(t *testing.T) {
+	fe := NewFakeEngine()
+	fe.Options = append(fe.Options,
+		"option name Clear Hash type button",
+		"option name Skill Level type spin default 20 min 0 max 20",
+		"option name SyzygyPath type string default <empty>",
+		"option name Style type combo default Normal var Solid var Normal var Risky",
+	)
+	m, _ := newEngineTestManager(t, fe)
+
+	if err := m.AnalyzeCurrentPosition(AnalysisLimits{}); err == nil {
+		t.Error("analysis started without an engine")
+	}
+	info, err := m.StartEngine(EngineConfig{Path: "fake", Options: map[string]string{"skill level": "5"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Running || info.Name != "Fake Engine" {
+		t.Errorf("info = running %v name %q, want a running Fake Engine", info.Running, info.Name)
+	}
+
+	want := []EngineOption{
+		{Name: "MultiPV", Type: "spin", Default: "1", Min: 1, Max: 5, Vars: []string{}, Value: "1"},
+		{Name: "Clear Hash", Type: "button", Vars: []string{}},
+		{Name: "Skill Level", Type: "spin", Default: "20", Min: 0, Max: 20, Vars: []string{}, Value: "5"},
+		{Name: "SyzygyPath", Type: "string", Vars: []string{}},
+		{Name: "Style", Type: "combo", Default: "Normal", Vars: []string{"Solid", "Normal", "Risky"}, Value: "Normal"},
+	}
+	if len(info.Options) != len(want) {
+		t.Fatalf("got %d options, want %d: %+v", len(info.Options), len(want), info.Options)
+	}
+	for i, opt := range info.Options {
+		w := want[i]
+		if opt.Name != w.Name || opt.Type != w.Type || opt.Default != w.Default || opt.Min != w.Min ||
+			opt.Max != w.Max || !slices.Equal(opt.Vars, w.Vars) || opt.Value != w.Value {
+			t.Errorf("option %d = %+v, want %+v", i, opt, w)
+		}
+	}
+	if !slices.Contains(fe.Commands, "setoption name Skill Level value 5") {
+		t.Errorf("configured option not sent: %q", fe.Commands)
+	}
+
+	if err := m.SetEngineOption("Clear Hash", ""); err != nil {
+		t.Errorf("button: %v", err)
+	}
+	for _, bad := range [][2]string{{"Skill Level", "50"}, {"Skill Level", "x"}, {"Ponder", "true"}} {
+		if err := m.SetEngineOption(bad[0], bad[1]); err == nil {
+			t.Errorf("SetEngineOption(%q, %q) accepted", bad[0], bad[1])
+		}
+	}
+	if _, err := m.StartEngine(EngineConfig{Options: map[string]string{"Skill Level": "50"}}); err == nil {
+		t.Error("engine started with an out of range option")
+	}
+	if m.GetEngineInfo().Running {
+		t.Error("engine still running after a failed start")
+	}
+}
+
+func TestAnalyzeCurrentPosition(t *testing.T) {
+	fe := NewFakeEngine()
+	black, err := ApplyMoveSAN(StartFEN, "e4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fe.Analysis[black] = []string{
+		"info string NNUE evaluation enabled",
+		"info depth 1 seldepth 1 multipv 1 score cp 30 nodes 20 nps 1000 time 1 pv e7e5",
+		"info depth 1 seldepth 1 multipv 2 score cp 20 nodes 40 nps 1000 time 1 pv c7c5 g1f3",
+		"info depth 2 seldepth 2 multipv 1 score cp 45 lowerbound nodes 60 time 2 pv e7e5",
+		"info depth 2 seldepth 2 multipv 1 score cp 35 nodes 80 nps 1000 time 2 pv e7e5 g1f3 b8c6",
+		"info depth 2 seldepth 2 multipv 2 score mate 3 nodes 90 nps 1000 time 2 pv c7c5 g1f3",
+	}
+	m, events := newEngineTestManager(t, fe)
+	if _, err := m.StartEngine(EngineConfig{Path: "fake"}); err != nil {
+		t.Fatal(err)
+	}
+	m.SetCurrentFEN(black)
+
+	if err := m.AnalyzeCurrentPosition(AnalysisLimits{Lines: 10, Depth: 2}); err != nil {
+		t.Fatal(err)
+	}
+	updates, final := waitDone(t, events)
+
+	if !slices.Contains(fe.Commands, "setoption name MultiPV value 5") {
+		t.Errorf("MultiPV not clamped to the option's max: %q", fe.Commands)
+	}
+	if !slices.Contains(fe.Commands, "go depth 2") {
+		t.Errorf("search not limited to depth 2: %q", fe.Commands)
+	}
+	if len(updates) == 0 || len(updates) >= 4 {
+		t.Errorf("got %d updates for 4 variations, want them throttled", len(updates))
+	}
+	for _, a := range updates {
+		if a.Done || a.FEN != black {
+			t.Errorf("update = done %v fen %q, want an unfinished analysis of %q", a.Done, a.FEN, black)
+		}
+	}
+	select {
+	case a := <-events:
+		t.Errorf("event after the final one: %+v", a)
+	default:
+	}
+
+	if final.FEN != black || final.Error != "" || final.BestMove != "e5" || final.Depth != 2 || final.Nodes != 90 {
+		t.Errorf("final = fen %q error %q best %q depth %d nodes %d, want %q, no error, e5, depth 2, 90 nodes",
+			final.FEN, final.Error, final.BestMove, final.Depth, final.Nodes, black)
+	}
+	want := []PVLine{
+		// Black is to move, so the engine's scores are negated to White's point of view.
+		{Rank: 1, Depth: 2, Score: -35, Moves: []string{"e5", "Nf3", "Nc6"}, UCI: []string{"e7e5", "g1f3", "b8c6"}},
+		{Rank: 2, Depth: 2, Mate: -3, Moves: []string{"c5", "Nf3"}, UCI: []string{"c7c5", "g1f3"}},
+	}
+	if len(final.Lines) != len(want) {
+		t.Fatalf("final has %d lines, want %d: %+v", len(final.Lines), len(want), final.Lines)
+	}
+	for i, pv := range final.Lines {
+		w := want[i]
+		if pv.Rank != w.Rank || pv.Depth != w.Depth || pv.Score != w.Score || pv.Mate != w.Mate ||
+			!slices.Equal(pv.Moves, w.Moves) || !slices.Equal(pv.UCI, w.UCI) {
+			t.Errorf("line %d = %+v, want %+v", i+1, pv, w)
+		}
+	}
+}
+
+func TestAnalysisWithoutMultiPV(t *testing.T) {
+	fe := NewFakeEngine()
+	fe.Options = nil
+	fe.Analysis[StartFEN] = []string{"info depth 1 score cp 25 nodes 10 pv d2d4"}
+	m, events := newEngineTestManager(t, fe)
+	if _, err := m.StartEngine(EngineConfig{Path: "fake"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AnalyzeCurrentPosition(AnalysisLimits{Lines: 3, MoveTime: 500}); err != nil {
+		t.Fatal(err)
+	}
+	_, final := waitDone(t, events)
+	if slices.ContainsFunc(fe.Commands, func(cmd string) bool { return strings.HasPrefix(cmd, "setoption name MultiPV") }) {
+		t.Errorf("MultiPV set on an engine without it: %q", fe.Commands)
+	}
+	if !slices.Contains(fe.Commands, "go movetime 500") {
+		t.Errorf("search not limited to 500ms: %q", fe.Commands)
+	}
+	if len(final.Lines) != 1 || final.Lines[0].Score != 25 || !slices.Equal(final.Lines[0].Moves, []string{"d4"}) {
+		t.Errorf("final lines = %+v, want d4 at +25", final.Lines)
+	}
+}
+
+func TestInfiniteAnalysisStopsOnMove(t *testing.T) {
+	fe := NewFakeEngine()
+	fe.Analysis[StartFEN] = []string{"info depth 12 multipv 1 score cp 20 nodes 5000 time 40 pv e2e4 e7e5"}
+	m, events := newEngineTestManager(t, fe)
+	if _, err := m.StartEngine(EngineConfig{Path: "fake"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AnalyzeCurrentPosition(AnalysisLimits{}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case a := <-events:
+		if a.Done {
+			t.Fatalf("infinite analysis finished by itself: %+v", a)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no analysis update")
+	}
+	if !slices.Contains(fe.Commands, "go infinite") {
+		t.Fatalf("search not infinite: %q", fe.Commands)
+	}
+	if !m.GetEngineInfo().Analyzing {
+		t.Error("engine not reported as analyzing")
+	}
+
+	e4, err := ApplyMoveSAN(StartFEN, "e4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetCurrentFEN(e4)
+	_, final := waitDone(t, events)
+	if final.FEN != StartFEN || final.Error != "" || final.BestMove != "e4" {
+		t.Errorf("final = fen %q error %q best %q, want the start position stopped cleanly with e4",
+			final.FEN, final.Error, final.BestMove)
+	}
+	if !slices.Contains(fe.Commands, "stop") {
+		t.Errorf("search not stopped: %q", fe.Commands)
+	}
+
+	m.StopAnalysis()
+	if m.GetEngineInfo().Analyzing {
+		t.Error("engine still analyzing after the position changed")
+	}
+	if err := m.StopEngine(); err != nil {
+		t.Fatal(err)
+	}
+	if fe.Commands[len(fe.Commands)-1] != "quit" {
+		t.Errorf("engine not told to quit: %q", fe.Commands)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package backend
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// createNoWindow keeps console engines from opening a console window of their own.
+const createNoWindow = 0x08000000
+
+func hideWindow(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true, CreationFlags: createNoWindow}
+}
@@ -0,0 +1,242 @@
+package backend
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// CacheConfig controls how long explorer results are reused.
+type CacheConfig struct {
+	TTL      time.Duration // entries younger than this are served without refetching
+	MaxStale time.Duration // entries up to TTL+MaxStale old are served while refreshing in the background
+}
+
+// DefaultCacheConfig keeps results fresh for a week and serves them stale for a month more.
+var DefaultCacheConfig = CacheConfig{TTL: 7 * 24 * time.Hour, MaxStale: 30 * 24 * time.Hour}
+
+// ExplorerCache is an ExplorerProvider that stores upstream results in the stats table,
+// keyed by position and query parameters, so transpositions share an entry.
+type ExplorerCache struct {
+	db       *sql.DB
+	upstream ExplorerProvider
+	cfg      CacheConfig
+	now      func() time.Time
+	base     func() context.Context // context of background refreshes
+
+	mu         sync.Mutex
+	refreshing map[string]bool
+}
+
+// NewExplorerCache wraps upstream with a persistent cache in db.
+func NewExplorerCache(db *sql.DB, upstream ExplorerProvider, cfg CacheConfig) *ExplorerCache {
+	return &ExplorerCache{
+		db:         db,
+		upstream:   upstream,
+		cfg:        cfg,
+		now:        time.Now,
+		base:       context.Background,
+		refreshing: make(map[string]bool),
+	}
+}
+
+// cachedStats is one row of the stats table.
+type cachedStats struct {
+	data      ExplorerResponse
+	fetchedAt time.Time
+}
+
+// Explore serves fresh entries from the cache, serves stale ones while refreshing them
+// in the background, and falls back to a stale entry if the upstream fetch fails.
+func (c *ExplorerCache) Explore(ctx context.Context, fen string, q ExplorerQuery) (ExplorerResponse, error) {
+	entry, err := c.load(ctx, fen, q)
+	if err != nil {
+		return ExplorerResponse{}, err
+	}
+	if entry != nil {
+		age := c.now().Sub(entry.fetchedAt)
+		if age < c.cfg.TTL {
+			return entry.data, nil
+		}
+		if age < c.cfg.TTL+c.cfg.MaxStale {
+			c.revalidate(fen, q)
+			return entry.data, nil
+		}
+	}
+
+	data, err := c.Refresh(ctx, fen, q)
+	if err != nil && entry != nil && ctx.Err() == nil {
+		log.Printf("explorer cache: serving expired entry for %s: %v", fen, err)
+		return entry.data, nil
+	}
+	return data, err
+}
+
+// Refresh fetches fen from upstream and stores the result regardless of cache age.
+func (c *ExplorerCache) Refresh(ctx context.Context, fen string, q ExplorerQuery) (ExplorerResponse, error) {
+	data, err := c.upstream.Explore(ctx, fen, q)
+	if err != nil {
+		return ExplorerResponse{}, err
+	}
+	if err := c.store(ctx, fen, q, data); err != nil {
+		return ExplorerResponse{}, err
+	}
+	return data, nil
+}
+
+// Fresh reports whether fen has a cache entry younger than the TTL.
+func (c *ExplorerCache) Fresh(ctx context.Context, fen string, q ExplorerQuery) (bool, error) {
+	entry, err := c.load(ctx, fen, q)
+	if err != nil || entry == nil {
+		return false, err
+	}
+	return c.now().Sub(entry.fetchedAt) < c.cfg.TTL, nil
+}
+
+// revalidate refreshes an entry in the background, at most once at a time per key.
+// The refresh is abandoned when the base context ends, e.g. on shutdown.
+func (c *ExplorerCache) revalidate(fen string, q ExplorerQuery) {
+	key := q.Key() + "|" + fen
+	c.mu.Lock()
+	if c.refreshing[key] {
+		c.mu.Unlock()
+		return
+	}
+	c.refreshing[key] = true
+	c.mu.Unlock()
+
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.refreshing, key)
+			c.mu.Unlock()
+		}()
+		if _, err := c.Refresh(c.base(), fen, q); err != nil {
+			log.Printf("explorer cache: background refresh of %s failed: %v", fen, err)
+		}
+	}()
+}
+
+func (c *ExplorerCache) load(ctx context.Context, fen string, q ExplorerQuery) (*cachedStats, error) {
+	var (
+		entry              cachedStats
+		movesJSON, opening string
+		fetchedAt          int64
+	)
+	err := c.db.QueryRowContext(ctx,
+		`SELECT white_win, black_win, draw, moves, opening, fetched_at FROM stats WHERE fen = ? AND query = ?`,
+		positionKey(fen), q.Key()).Scan(&entry.data.White, &entry.data.Black, &entry.data.Draws, &movesJSON, &opening, &fetchedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read explorer cache: %w", err)
+	}
+	if err := json.Unmarshal([]byte(movesJSON), &entry.data.Moves); err != nil {
+		return nil, fmt.Errorf("failed to decode cached moves: %w", err)
+	}
+	if err := json.Unmarshal([]byte(opening), &entry.data.Opening); err != nil {
+		return nil, fmt.Errorf("failed to decode cached opening: %w", err)
+	}
+	entry.fetchedAt = time.Unix(fetchedAt, 0)
+	return &entry, nil
+}
+
+func (c *ExplorerCache) store(ctx context.Context, fen string, q ExplorerQuery, data ExplorerResponse) error {
+	moves, err := json.Marshal(data.Moves)
+	if err != nil {
+		return err
+	}
+	opening, err := json.Marshal(data.Opening)
+	if err != nil {
+		return err
+	}
+	_, err = c.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO stats (fen, query, games, white_win, black_win, draw, moves, opening, fetched_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		positionKey(fen), q.Key(), data.White+data.Black+data.Draws, data.White, data.Black, data.Draws,
+		string(moves), string(opening), c.now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to write explorer cache: %w", err)
+	}
+	return nil
+}
+
+// PurgeExplorerCache removes the statistics cached under a repertoire's explorer
+// settings for every position in it. Entries are shared, so other repertoires with the
+// same settings refetch these positions too; statistics cached for other settings,
+// such as a source picked while browsing, are kept.
+func (m *RepertoireManager) PurgeExplorerCache(repID int64) (int64, error) {
+	ctx := m.baseContext()
+	q, err := m.repertoireExplorerQuery(ctx, repID)
+	if err != nil {
+		return 0, err
+	}
+	res, err := m.db.ExecContext(ctx,
+		`DELETE FROM stats WHERE query = ? AND fen IN (SELECT fen FROM nodes WHERE rep_id = ?)`, q.Key(), repID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge explorer cache: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// WarmExplorerCache fetches statistics for every position in a repertoire whose cache
+// entry is missing or stale, and returns how many positions were fetched.
+func (m *RepertoireManager) WarmExplorerCache(repID int64) (int, error) {
+	if m.cache == nil {
+		return 0, fmt.Errorf("explorer cache is disabled")
+	}
+
+	ctx, done := m.beginOperation()
+	defer done()
+	q, err := m.repertoireExplorerQuery(ctx, repID)
+	if err != nil {
+		return 0, canceledError(err)
+	}
+
+	fens, err := m.repertoireFENs(ctx, repID)
+	if err != nil {
+		return 0, canceledError(err)
+	}
+	fetched := 0
+	for _, fen := range fens {
+		if err := checkContext(ctx); err != nil {
+			return fetched, err
+		}
+		fresh, err := m.cache.Fresh(ctx, fen, q)
+		if err != nil {
+			return fetched, canceledError(err)
+		}
+		if fresh {
+			continue
+		}
+		if _, err := m.cache.Refresh(ctx, fen, q); err != nil {
+			return fetched, fmt.Errorf("failed to warm %s: %w", fen, canceledError(err))
+		}
+		fetched++
+	}
+	return fetched, nil
+}
+
+// repertoireFENs lists every position stored in a repertoire.
+func (m *RepertoireManager) repertoireFENs(ctx context.Context, repID int64) ([]string, error) {
+	rows, err := m.db.QueryContext(ctx,
+		`SELECT fen FROM nodes WHERE rep_id = ?`, repID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var fens []string
+	for rows.Next() {
+		var fen string
+		if err := rows.Scan(&fen); err != nil {
+			return nil, err
+		}
+		fens = append(fens, fen)
+	}
+	return fens, rows.Err()
+}
@@ -0,0 +1,189 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestCache returns a cache in front of a FakeExplorer whose clock is *now.
+func newTestCache(t *testing.T, now *time.Time) (*ExplorerCache, *FakeExplorer) {
+	t.Helper()
+	db, err := Open("file:" + filepath.Join(t.TempDir(), "repertoire.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	fe := NewFakeExplorer()
+	c := NewExplorerCache(db.SQL, fe, CacheConfig{TTL: time.Hour, MaxStale: 24 * time.Hour})
+	c.now = func() time.Time { return *now }
+	return c, fe
+}
+
+// waitRefreshed waits for the background refreshes of c to finish.
+func waitRefreshed(t *testing.T, c *ExplorerCache) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.mu.Lock()
+		n := len(c.refreshing)
+		c.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background refresh did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (f *FakeExplorer) calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.Calls)
+}
+
+func (f *FakeExplorer) set(fen string, white int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Responses[fen] = ExplorerResponse{White: white}
+	f.Err = err
+}
+
+func TestExplorerCache(t *testing.T) {
+	ctx := context.Background()
+	q := DefaultExplorerQuery(1500)
+	now := testNow
+	c, fe := newTestCache(t, &now)
+	fe.set(StartFEN, 1, nil)
+	explore := func() int {
+		t.Helper()
+		data, err := c.Explore(ctx, StartFEN, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data.White
+	}
+
+	if got := explore(); got != 1 || fe.calls() != 1 {
+		t.Fatalf("first lookup = %d after %d calls, want 1 from one fetch", got, fe.calls())
+	}
+	fe.set(StartFEN, 2, nil)
+	now = testNow.Add(59 * time.Minute)
+	if got := explore(); got != 1 || fe.calls() != 1 {
+		t.Errorf("fresh lookup = %d after %d calls, want the cached 1 without a fetch", got, fe.calls())
+	}
+	// Other query parameters have their own entry.
+	if _, err := c.Explore(ctx, StartFEN, DefaultExplorerQuery(2200)); err != nil || fe.calls() != 2 {
+		t.Errorf("lookup with another rating band made %d calls, err %v; want a second fetch", fe.calls(), err)
+	}
+
+	// Stale entries are served while a background refresh replaces them.
+	now = testNow.Add(2 * time.Hour)
+	if got := explore(); got != 1 {
+		t.Errorf("stale lookup = %d, want the stale 1 served at once", got)
+	}
+	waitRefreshed(t, c)
+	if fe.calls() != 3 {
+		t.Errorf("%d calls after a stale lookup, want a background fetch", fe.calls())
+	}
+	if got := explore(); got != 2 || fe.calls() != 3 {
+		t.Errorf("lookup after the refresh = %d after %d calls, want the refreshed 2 from the cache", got, fe.calls())
+	}
+
+	// Expired entries are refetched before answering.
+	fe.set(StartFEN, 3, nil)
+	now = now.Add(26 * time.Hour)
+	if got := explore(); got != 3 || fe.calls() != 4 {
+		t.Errorf("expired lookup = %d after %d calls, want 3 fetched at once", got, fe.calls())
+	}
+
+	// When the provider fails, an expired entry is better than nothing.
+	providerErr := errors.New("offline")
+	fe.set(StartFEN, 4, providerErr)
+	now = now.Add(26 * time.Hour)
+	if got := explore(); got != 3 {
+		t.Errorf("lookup with the provider down = %d, want the expired 3", got)
+	}
+	e4, err := ApplyMoveSAN(StartFEN, "e4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Explore(ctx, e4, q); !errors.Is(err, providerErr) {
+		t.Errorf("uncached lookup with the provider down: err = %v, want %v", err, providerErr)
+	}
+
+	// A failed background refresh keeps the stale entry.
+	fe.set(StartFEN, 4, nil)
+	if got := explore(); got != 4 {
+		t.Fatalf("lookup = %d, want 4", got)
+	}
+	fe.set(StartFEN, 5, providerErr)
+	now = now.Add(2 * time.Hour)
+	explore()
+	waitRefreshed(t, c)
+	fe.set(StartFEN, 5, nil)
+	if got := explore(); got != 4 {
+		t.Errorf("lookup after a failed refresh = %d, want the stale 4 kept", got)
+	}
+	waitRefreshed(t, c)
+}
+
+func TestExplorerCacheBackgroundRefreshStopsWithApplication(t *testing.T) {
+	now := testNow
+	c, fe := newTestCache(t, &now)
+	q := DefaultExplorerQuery(1500)
+	if _, err := c.Explore(context.Background(), StartFEN, q); err != nil {
+		t.Fatal(err)
+	}
+	base, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.base = func() context.Context { return base }
+	now = now.Add(2 * time.Hour)
+	if _, err := c.Explore(context.Background(), StartFEN, q); err != nil {
+		t.Fatal(err)
+	}
+	waitRefreshed(t, c)
+	if fresh, _ := c.Fresh(context.Background(), StartFEN, q); fresh || fe.calls() != 2 {
+		t.Errorf("refresh after shutdown: fresh %v after %d calls, want it abandoned", fresh, fe.calls())
+	}
+}
+
+func TestPurgeExplorerCache(t *testing.T) {
+	m, repID := newTestManager(t)
+	addLine(t, m, "e4", "e5")
+	masters, err := m.Create("Masters", "white", 1500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetExplorerQuery(masters, ExplorerQuery{Database: ExplorerMasters}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int64{repID, masters} {
+		if _, err := m.WarmExplorerCache(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cached := func() int {
+		t.Helper()
+		var n int
+		if err := m.db.QueryRow(`SELECT COUNT(1) FROM stats`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := cached(); n != 4 {
+		t.Fatalf("%d cached positions, want the 3 of Main and the start of Masters", n)
+	}
+
+	n, err := m.PurgeExplorerCache(repID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || cached() != 1 {
+		t.Errorf("purged %d, %d left; want Main's 3 purged and Masters' start position kept", n, cached())
+	}
+}
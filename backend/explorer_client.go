@@ -0,0 +1,155 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Kinds of explorer failure, matched with errors.Is on an *ExplorerError.
+var (
+	ErrExplorerRateLimited = errors.New("explorer rate limit reached")
+	ErrExplorerUnavailable = errors.New("explorer unavailable")
+	ErrExplorerRequest     = errors.New("explorer rejected the request")
+	ErrExplorerResponse    = errors.New("explorer sent an invalid response")
+	ErrExplorerTimeout     = errors.New("explorer timed out")
+)
+
+// ExplorerError is returned by LichessExplorer once retries are exhausted.
+type ExplorerError struct {
+	Kind       error         // one of the ErrExplorer* values
+	StatusCode int           // HTTP status, 0 when no response was received
+	RetryAfter time.Duration // server-requested wait, if any
+	Err        error         // underlying cause
+}
+
+func (e *ExplorerError) Error() string {
+	msg := e.Kind.Error()
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(" (HTTP %d)", e.StatusCode)
+	}
+	if e.RetryAfter > 0 {
+		msg += fmt.Sprintf(", retry in %s", e.RetryAfter.Round(time.Second))
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ExplorerError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// retryable reports whether another attempt may succeed.
+func (e *ExplorerError) retryable() bool {
+	return e.Kind == ErrExplorerRateLimited || e.Kind == ErrExplorerUnavailable || e.Kind == ErrExplorerTimeout
+}
+
+// classifyStatus turns a non-200 response into an ExplorerError.
+func classifyStatus(resp *http.Response) *ExplorerError {
+	e := &ExplorerError{StatusCode: resp.StatusCode}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		e.Kind = ErrExplorerRateLimited
+		e.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	case resp.StatusCode >= 500:
+		e.Kind = ErrExplorerUnavailable
+	default:
+		e.Kind = ErrExplorerRequest
+	}
+	return e
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(h string, now time.Time) time.Duration {
+	if h == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(h); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// RateLimiter spaces requests at least Interval apart and can be paused
+// when the server asks clients to back off.
+type RateLimiter struct {
+	Interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// explorerLimiter is shared by every LichessExplorer unless one is given its own.
+// Lichess asks clients to send one request at a time.
+var explorerLimiter = &RateLimiter{Interval: 500 * time.Millisecond}
+
+// Wait blocks until a request may be sent or ctx is done. A caller that gives up
+// hands its slot back, so abandoned requests do not delay the ones still waiting.
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	now := time.Now()
+	at := r.next
+	if at.Before(now) {
+		at = now
+	}
+	r.next = at.Add(r.Interval)
+	r.mu.Unlock()
+	if err := sleepContext(ctx, at.Sub(now)); err != nil {
+		r.mu.Lock()
+		if r.next.Equal(at.Add(r.Interval)) {
+			r.next = at
+		}
+		r.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Pause holds back every request until d has passed.
+func (r *RateLimiter) Pause(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if until := time.Now().Add(d); until.After(r.next) {
+		r.next = until
+	}
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// backoff returns the wait before retry attempt n (0-based): exponential with full jitter.
+func backoff(base, max time.Duration, n int) time.Duration {
+	d := max
+	if n < 62 && base <= max>>n { // base<<n neither overflows nor exceeds max
+		d = base << n
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
@@ -0,0 +1,219 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// explorerServer serves the responses of handler and records when each request came.
+type explorerServer struct {
+	mu       sync.Mutex
+	requests []time.Time
+}
+
+func (s *explorerServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+// newTestLichess returns a client of a test server answering with handler, given the
+// number of the request from 0. Retries wait at most a few milliseconds.
+func newTestLichess(t *testing.T, handler func(n int, w http.ResponseWriter, r *http.Request)) (*LichessExplorer, *explorerServer) {
+	t.Helper()
+	s := &explorerServer{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		n := len(s.requests)
+		s.requests = append(s.requests, time.Now())
+		s.mu.Unlock()
+		handler(n, w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return &LichessExplorer{
+		BaseURL:    srv.URL,
+		Client:     srv.Client(),
+		Timeout:    time.Second,
+		MaxRetries: 3,
+		Backoff:    time.Millisecond,
+		MaxBackoff: 2 * time.Millisecond,
+		Limiter:    &RateLimiter{},
+	}, s
+}
+
+const explorerJSON = `{"white": 6, "black": 3, "draws": 1, "moves": [{"uci": "e2e4", "san": "e4", "white": 6, "black": 3, "draws": 1}]}`
+
+func TestLichessExplorerHonoursRetryAfter(t *testing.T) {
+	l, s := newTestLichess(t, func(n int, w http.ResponseWriter, r *http.Request) {
+		if n == 0 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(explorerJSON))
+	})
+	data, err := l.Explore(context.Background(), StartFEN, DefaultExplorerQuery(1500))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.White != 6 || len(data.Moves) != 1 || data.Moves[0].SAN != "e4" {
+		t.Errorf("data = %+v, want the second response", data)
+	}
+	if s.count() != 2 {
+		t.Fatalf("%d requests, want 2", s.count())
+	}
+	if wait := s.requests[1].Sub(s.requests[0]); wait < time.Second {
+		t.Errorf("retried after %s, want the server's 1s", wait)
+	}
+}
+
+func TestLichessExplorerErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  func(w http.ResponseWriter, r *http.Request)
+		kind     error
+		status   int
+		requests int // attempts made with MaxRetries 3
+	}{
+		{
+			name: "rate limited",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			kind: ErrExplorerRateLimited, status: 429, requests: 4,
+		},
+		{
+			name:    "server error",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusBadGateway) },
+			kind:    ErrExplorerUnavailable, status: 502, requests: 4,
+		},
+		{
+			name:    "bad request",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusBadRequest) },
+			kind:    ErrExplorerRequest, status: 400, requests: 1,
+		},
+		{
+			name:    "not JSON",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("<html>maintenance</html>")) },
+			kind:    ErrExplorerResponse, status: 200, requests: 1,
+		},
+		{
+			name:    "wrong JSON type",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`{"white": "many"}`)) },
+			kind:    ErrExplorerResponse, status: 200, requests: 1,
+		},
+		{
+			name:    "truncated JSON",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`{"white": 6, "moves": [`)) },
+			kind:    ErrExplorerResponse, status: 200, requests: 1,
+		},
+		{
+			name: "connection dropped",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "1000")
+				w.Write([]byte(`{"white": 6`))
+				w.(http.Flusher).Flush()
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err == nil {
+					conn.Close()
+				}
+			},
+			kind: ErrExplorerUnavailable, requests: 4,
+		},
+		{
+			name: "timeout",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(time.Second):
+				}
+			},
+			kind: ErrExplorerTimeout, requests: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, s := newTestLichess(t, func(n int, w http.ResponseWriter, r *http.Request) { tt.handler(w, r) })
+			l.Timeout = 20 * time.Millisecond
+			_, err := l.Explore(context.Background(), StartFEN, DefaultExplorerQuery(1500))
+			var xerr *ExplorerError
+			if !errors.As(err, &xerr) {
+				t.Fatalf("err = %v, want an ExplorerError", err)
+			}
+			if !errors.Is(err, tt.kind) || xerr.StatusCode != tt.status {
+				t.Errorf("err = %v, want %v with status %d", err, tt.kind, tt.status)
+			}
+			if s.count() != tt.requests {
+				t.Errorf("%d requests, want %d", s.count(), tt.requests)
+			}
+		})
+	}
+}
+
+func TestLichessExplorerCancel(t *testing.T) {
+	l, s := newTestLichess(t, func(n int, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	l.Backoff, l.MaxBackoff = time.Hour, time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := l.Explore(ctx, StartFEN, DefaultExplorerQuery(1500))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the caller's deadline", err)
+	}
+	if s.count() != 1 {
+		t.Errorf("%d requests, want 1 before the backoff was cut short", s.count())
+	}
+}
+
+func TestBackoffLimits(t *testing.T) {
+	base, max := 100*time.Millisecond, 5*time.Second
+	for n := 0; n < 70; n++ {
+		limit := max
+		if float64(base)*math.Pow(2, float64(n)) < float64(max) {
+			limit = base << n
+		}
+		for range 20 {
+			if d := backoff(base, max, n); d <= 0 || d > limit {
+				t.Fatalf("backoff(%d) = %s, want within (0, %s]", n, d, limit)
+			}
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	r := &RateLimiter{Interval: time.Hour}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.Wait(canceled); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled Wait = %v, want context.Canceled", err)
+	}
+	if !r.next.IsZero() {
+		t.Fatalf("cancelled Wait reserved a slot at %s", r.next)
+	}
+
+	if err := r.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	reserved := r.next
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := r.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait = %v, want the deadline while the first slot is taken", err)
+	}
+	if !r.next.Equal(reserved) {
+		t.Errorf("next slot at %s after giving up, want %s", r.next, reserved)
+	}
+
+	r.Pause(2 * time.Hour)
+	if r.next.Before(reserved.Add(time.Hour)) {
+		t.Errorf("next slot at %s after a pause, want at least two hours away", r.next)
+	}
+}
@@ -0,0 +1,37 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// FakeExplorer is an in-memory ExplorerProvider for tests and offline development.
+// Responses are looked up by FEN; unknown positions return an empty response.
+type FakeExplorer struct {
+	mu        sync.Mutex
+	Responses map[string]ExplorerResponse
+	Err       error           // returned from every call when set
+	Calls     []string        // FENs requested, in order
+	Queries   []ExplorerQuery // the query of each call
+}
+
+// NewFakeExplorer returns a FakeExplorer with no canned responses.
+func NewFakeExplorer() *FakeExplorer {
+	return &FakeExplorer{Responses: make(map[string]ExplorerResponse)}
+}
+
+// Explore records the call and returns the canned response for fen.
+func (f *FakeExplorer) Explore(ctx context.Context, fen string, q ExplorerQuery) (ExplorerResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Calls = append(f.Calls, fen)
+	f.Queries = append(f.Queries, q)
+	if err := ctx.Err(); err != nil {
+		return ExplorerResponse{}, err
+	}
+	if f.Err != nil {
+		return ExplorerResponse{}, fmt.Errorf("fake explorer: %w", f.Err)
+	}
+	return f.Responses[fen], nil
+}
@@ -0,0 +1,127 @@
+package backend
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// localExplorerMaxPly bounds how deep into each game positions are indexed.
+const localExplorerMaxPly = 40
+
+// LocalExplorer answers explorer queries from games indexed into the local database,
+// so statistics are available offline. Game metadata is not tracked, so the query is ignored.
+type LocalExplorer struct {
+	db *sql.DB
+}
+
+// NewLocalExplorer returns a provider backed by the local_moves table.
+func NewLocalExplorer(db *sql.DB) *LocalExplorer {
+	return &LocalExplorer{db: db}
+}
+
+// Explore aggregates the indexed results of every move played from fen.
+func (l *LocalExplorer) Explore(ctx context.Context, fen string, q ExplorerQuery) (ExplorerResponse, error) {
+	rows, err := l.db.QueryContext(ctx,
+		`SELECT uci, san, white, black, draws FROM local_moves WHERE fen = ?
+		 ORDER BY white + black + draws DESC`, positionKey(fen))
+	if err != nil {
+		return ExplorerResponse{}, fmt.Errorf("failed to query local explorer: %w", err)
+	}
+	defer rows.Close()
+
+	data := ExplorerResponse{Moves: []Move{}}
+	for rows.Next() {
+		var mv Move
+		if err := rows.Scan(&mv.UCI, &mv.SAN, &mv.White, &mv.Black, &mv.Draws); err != nil {
+			return ExplorerResponse{}, err
+		}
+		data.White += mv.White
+		data.Black += mv.Black
+		data.Draws += mv.Draws
+		data.Moves = append(data.Moves, mv)
+	}
+	return data, rows.Err()
+}
+
+// AddPGN indexes the main line of every decided game in pgn and returns how many were added.
+// Games without a result are skipped since they cannot contribute to win rates, and so
+// are games with an illegal move, so no partial game is indexed.
+func (l *LocalExplorer) AddPGN(ctx context.Context, pgn string) (int, error) {
+	games, err := ParsePGN(strings.NewReader(pgn))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse PGN: %w", err)
+	}
+
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	added := 0
+	for _, g := range games {
+		if err := checkContext(ctx); err != nil {
+			return 0, err
+		}
+		var white, black, draws int
+		switch g.Result {
+		case "1-0":
+			white = 1
+		case "0-1":
+			black = 1
+		case "1/2-1/2":
+			draws = 1
+		default:
+			continue
+		}
+		fen := g.Tag("FEN")
+		if fen == "" {
+			fen = StartFEN
+		}
+		pos, err := positionFromFEN(fen)
+		if err != nil {
+			continue
+		}
+		// Replay the whole game before indexing it so an illegal move skips all of it.
+		type indexedMove struct{ fen, uci, san string }
+		var moves []indexedMove
+		legal := true
+		for i, pm := range g.Moves {
+			if i >= localExplorerMaxPly {
+				break
+			}
+			mv, san, err := decodeMove(pos, pm.SAN)
+			if err != nil {
+				legal = false
+				break
+			}
+			moves = append(moves, indexedMove{positionKey(pos.String()), chess.UCINotation{}.Encode(pos, mv), san})
+			pos = pos.Update(mv)
+		}
+		if !legal {
+			continue
+		}
+		for _, mv := range moves {
+			_, err = tx.ExecContext(ctx,
+				`INSERT INTO local_moves (fen, uci, san, white, black, draws) VALUES (?, ?, ?, ?, ?, ?)
+				 ON CONFLICT (fen, uci) DO UPDATE SET
+				   white = white + excluded.white,
+				   black = black + excluded.black,
+				   draws = draws + excluded.draws`,
+				mv.fen, mv.uci, mv.san, white, black, draws)
+			if err != nil {
+				return 0, fmt.Errorf("failed to index move: %w", err)
+			}
+		}
+		added++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return added, nil
+}
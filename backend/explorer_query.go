@@ -0,0 +1,314 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Explorer databases.
+const (
+	ExplorerLichess = "lichess"
+	ExplorerMasters = "masters"
+	ExplorerPlayer  = "player"
+)
+
+// explorerSpeeds are the time controls accepted by the Lichess explorer.
+var explorerSpeeds = []string{"ultraBullet", "bullet", "blitz", "rapid", "classical", "correspondence"}
+
+// explorerRatings are the lower bounds of the Lichess explorer rating bands.
+var explorerRatings = []int{0, 1000, 1200, 1400, 1600, 1800, 2000, 2200, 2500}
+
+// explorerModes are the game modes accepted by the player explorer.
+var explorerModes = []string{"casual", "rated"}
+
+// ExplorerQuery selects which games the explorer aggregates.
+// Speeds and ratings apply to the lichess database; player and color to the player database.
+type ExplorerQuery struct {
+	Database string   `json:"database"` // "lichess", "masters" or "player"
+	Variant  string   `json:"variant"`
+	Speeds   []string `json:"speeds"`
+	Ratings  []int    `json:"ratings"` // rating band lower bounds
+	Since    string   `json:"since"`   // "YYYY-MM", empty for no bound
+	Until    string   `json:"until"`
+	Player   string   `json:"player"` // lichess username for the player database
+	Color    string   `json:"color"`  // side the player had, "white" or "black"
+	Modes    []string `json:"modes"`  // "casual" and/or "rated"
+}
+
+// DefaultExplorerQuery matches the original behaviour: rapid lichess games in the band containing elo.
+func DefaultExplorerQuery(elo int) ExplorerQuery {
+	return ExplorerQuery{}.withDefaults(elo)
+}
+
+// withDefaults fills unset fields, deriving the rating band from elo.
+func (q ExplorerQuery) withDefaults(elo int) ExplorerQuery {
+	if q.Database == "" {
+		q.Database = ExplorerLichess
+	}
+	if q.Variant == "" {
+		q.Variant = "standard"
+	}
+	if len(q.Speeds) == 0 {
+		q.Speeds = []string{"rapid"}
+	}
+	if len(q.Ratings) == 0 {
+		q.Ratings = []int{ratingBand(elo)}
+	}
+	if q.Database == ExplorerPlayer && q.Color == "" {
+		q.Color = "white"
+	}
+	return q
+}
+
+// ratingBand returns the highest explorer band whose lower bound is at most elo.
+func ratingBand(elo int) int {
+	band := explorerRatings[0]
+	for _, r := range explorerRatings {
+		if r <= elo {
+			band = r
+		}
+	}
+	return band
+}
+
+// Validate checks the query against the values the explorer accepts.
+func (q ExplorerQuery) Validate() error {
+	switch q.Database {
+	case "", ExplorerLichess, ExplorerMasters, ExplorerPlayer:
+	default:
+		return fmt.Errorf("unknown explorer database %q", q.Database)
+	}
+	for _, s := range q.Speeds {
+		if !slices.Contains(explorerSpeeds, s) {
+			return fmt.Errorf("unknown speed %q", s)
+		}
+	}
+	for _, r := range q.Ratings {
+		if !slices.Contains(explorerRatings, r) {
+			return fmt.Errorf("invalid rating band %d", r)
+		}
+	}
+	for _, mode := range q.Modes {
+		if !slices.Contains(explorerModes, mode) {
+			return fmt.Errorf("unknown mode %q", mode)
+		}
+	}
+	if q.Database == ExplorerPlayer {
+		if strings.TrimSpace(q.Player) == "" {
+			return fmt.Errorf("player database requires a player name")
+		}
+		if q.Color != "" && q.Color != "white" && q.Color != "black" {
+			return fmt.Errorf("invalid player color %q", q.Color)
+		}
+	}
+	for _, d := range []string{q.Since, q.Until} {
+		if d != "" && !validMonth(d) {
+			return fmt.Errorf("invalid month %q, expected YYYY-MM", d)
+		}
+	}
+	if q.Since != "" && q.Until != "" && q.Since > q.Until {
+		return fmt.Errorf("since %s is after until %s", q.Since, q.Until)
+	}
+	return nil
+}
+
+func validMonth(s string) bool {
+	y, mo, ok := strings.Cut(s, "-")
+	if !ok || len(y) != 4 || len(mo) != 2 {
+		return false
+	}
+	if _, err := strconv.Atoi(y); err != nil {
+		return false
+	}
+	n, err := strconv.Atoi(mo)
+	return err == nil && n >= 1 && n <= 12
+}
+
+// Values returns the URL parameters for the query, without the FEN.
+// Only the parameters the selected database understands are included.
+func (q ExplorerQuery) Values() url.Values {
+	v := url.Values{}
+	if q.Database == ExplorerMasters {
+		// The masters database filters by year only.
+		if q.Since != "" {
+			v.Set("since", q.Since[:4])
+		}
+		if q.Until != "" {
+			v.Set("until", q.Until[:4])
+		}
+		return v
+	}
+	v.Set("variant", q.Variant)
+	if q.Database == ExplorerPlayer {
+		v.Set("player", q.Player)
+		v.Set("color", q.Color)
+		if len(q.Modes) > 0 {
+			modes := slices.Clone(q.Modes)
+			sort.Strings(modes)
+			v.Set("modes", strings.Join(modes, ","))
+		}
+	}
+	if len(q.Speeds) > 0 {
+		speeds := slices.Clone(q.Speeds)
+		sort.Strings(speeds)
+		v.Set("speeds", strings.Join(speeds, ","))
+	}
+	if len(q.Ratings) > 0 && q.Database == ExplorerLichess {
+		ratings := slices.Clone(q.Ratings)
+		sort.Ints(ratings)
+		parts := make([]string, len(ratings))
+		for i, r := range ratings {
+			parts[i] = strconv.Itoa(r)
+		}
+		v.Set("ratings", strings.Join(parts, ","))
+	}
+	if q.Since != "" {
+		v.Set("since", q.Since)
+	}
+	if q.Until != "" {
+		v.Set("until", q.Until)
+	}
+	return v
+}
+
+// Key identifies the query canonically, e.g. for caching.
+func (q ExplorerQuery) Key() string {
+	return q.Database + "?" + q.Values().Encode()
+}
+
+// repertoireExplorerQuery loads the stored query of a repertoire with defaults applied.
+func (m *RepertoireManager) repertoireExplorerQuery(ctx context.Context, repID int64) (ExplorerQuery, error) {
+	var (
+		elo        int
+		color, raw string
+	)
+	err := m.db.QueryRowContext(ctx,
+		`SELECT elo, color, explorer_query FROM repertoire WHERE id = ?`, repID).Scan(&elo, &color, &raw)
+	if err != nil {
+		return ExplorerQuery{}, fmt.Errorf("failed to get explorer query: %w", err)
+	}
+	q, err := decodeExplorerQuery(raw)
+	if err != nil {
+		return ExplorerQuery{}, err
+	}
+	if q.Database == ExplorerPlayer && q.Color == "" {
+		// Prepare against the opponent, who plays the other side.
+		q.Color = oppositeColor(color)
+	}
+	return q.withDefaults(elo), nil
+}
+
+func oppositeColor(color string) string {
+	if color == "white" {
+		return "black"
+	}
+	return "white"
+}
+
+func decodeExplorerQuery(raw string) (ExplorerQuery, error) {
+	var q ExplorerQuery
+	if raw == "" {
+		return q, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &q); err != nil {
+		return ExplorerQuery{}, fmt.Errorf("invalid explorer query: %w", err)
+	}
+	return q, nil
+}
+
+// explorerSource is a session override of the database the explorer reads from.
+type explorerSource struct {
+	database string
+	player   string
+}
+
+// currentExplorerQuery returns the selected repertoire's query with any source override applied.
+func (m *RepertoireManager) currentExplorerQuery(ctx context.Context) (ExplorerQuery, error) {
+	m.mu.Lock()
+	repID, source := m.selectedRep, m.source
+	m.mu.Unlock()
+	if repID == 0 {
+		return ExplorerQuery{}, fmt.Errorf("no repertoire selected")
+	}
+	q, err := m.repertoireExplorerQuery(ctx, repID)
+	if err != nil {
+		return ExplorerQuery{}, err
+	}
+	if source == nil {
+		return q, nil
+	}
+	if q.Database != source.database || q.Player != source.player {
+		q.Database = source.database
+		q.Player = source.player
+		q.Color = ""
+		if q.Database == ExplorerPlayer {
+			var color string
+			err := m.db.QueryRowContext(ctx,
+				`SELECT color FROM repertoire WHERE id = ?`, repID).Scan(&color)
+			if err != nil {
+				return ExplorerQuery{}, fmt.Errorf("failed to get repertoire color: %w", err)
+			}
+			q.Color = oppositeColor(color)
+		}
+	}
+	return q, nil
+}
+
+// SetExplorerSource switches the statistics shown while browsing to another database
+// ("lichess", "masters" or "player" with a username) without changing the repertoire's
+// saved settings. An empty database goes back to the saved settings.
+func (m *RepertoireManager) SetExplorerSource(database, player string) error {
+	var source *explorerSource
+	if database != "" {
+		q := ExplorerQuery{Database: database, Player: player}
+		if err := q.Validate(); err != nil {
+			return err
+		}
+		source = &explorerSource{database: database, player: player}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.source = source
+	return nil
+}
+
+// GetExplorerSource returns the query currently used for GetCurrentWinrates.
+func (m *RepertoireManager) GetExplorerSource() (ExplorerQuery, error) {
+	return m.currentExplorerQuery(m.baseContext())
+}
+
+// GetExplorerQuery returns the explorer settings of the selected repertoire.
+func (m *RepertoireManager) GetExplorerQuery() (ExplorerQuery, error) {
+	repID, err := m.selected()
+	if err != nil {
+		return ExplorerQuery{}, err
+	}
+	return m.repertoireExplorerQuery(m.baseContext(), repID)
+}
+
+// SetExplorerQuery stores the explorer settings of a repertoire.
+// Empty fields fall back to defaults, with the rating band derived from the repertoire's elo.
+func (m *RepertoireManager) SetExplorerQuery(repID int64, q ExplorerQuery) error {
+	if err := q.Validate(); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+	res, err := m.db.ExecContext(m.baseContext(),
+		`UPDATE repertoire SET explorer_query = ? WHERE id = ?`, string(raw), repID)
+	if err != nil {
+		return fmt.Errorf("failed to save explorer query: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("repertoire %d not found", repID)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package backend
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestGetCurrentWinrates(t *testing.T) {
+	fe := NewFakeExplorer()
+	m, _ := newTestManager(t, WithExplorer(fe))
+	fe.Responses[StartFEN] = ExplorerResponse{White: 50, Black: 30, Draws: 20, Moves: []Move{
+		{SAN: "e4", UCI: "e2e4", White: 30, Black: 15, Draws: 15},
+		{SAN: "d4", UCI: "d2d4", White: 20, Black: 15, Draws: 5},
+	}}
+
+	pos, err := m.GetCurrentWinrates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos.Total != 100 || !near(pos.WhiteRate, 50) || !near(pos.BlackRate, 30) || !near(pos.DrawRate, 20) {
+		t.Errorf("position = %+v, want 100 games at 50/30/20", pos)
+	}
+	want := []MoveWinrate{
+		{SAN: "e4", UCI: "e2e4", Total: 60, WhiteRate: 50, BlackRate: 25, DrawRate: 25, Chance: 60},
+		{SAN: "d4", UCI: "d2d4", Total: 40, WhiteRate: 50, BlackRate: 37.5, DrawRate: 12.5, Chance: 40},
+	}
+	if len(pos.Moves) != len(want) {
+		t.Fatalf("got %d moves, want %d: %+v", len(pos.Moves), len(want), pos.Moves)
+	}
+	for i, mw := range pos.Moves {
+		w := want[i]
+		if mw.SAN != w.SAN || mw.UCI != w.UCI || mw.Total != w.Total || !near(mw.WhiteRate, w.WhiteRate) ||
+			!near(mw.BlackRate, w.BlackRate) || !near(mw.DrawRate, w.DrawRate) || !near(mw.Chance, w.Chance) {
+			t.Errorf("move %d = %+v, want %+v", i, mw, w)
+		}
+	}
+
+	// The explorer is asked about the position on the board.
+	if err := m.PlayMoveSAN("e4"); err != nil {
+		t.Fatal(err)
+	}
+	pos, err = m.GetCurrentWinrates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e4 := playLine(t, "e4")[0]; fe.Calls[len(fe.Calls)-1] != e4 {
+		t.Errorf("explorer asked about %q, want %q", fe.Calls[len(fe.Calls)-1], e4)
+	}
+	if pos.Total != 0 || pos.WhiteRate != 0 || len(pos.Moves) != 0 {
+		t.Errorf("unknown position = %+v, want no games", pos)
+	}
+
+	// Positions not cached yet need the provider.
+	fe.Err = errors.New("offline")
+	m.SetCurrentFEN(playLine(t, "d4")[0])
+	if _, err := m.GetCurrentWinrates(); !errors.Is(err, fe.Err) {
+		t.Errorf("err = %v, want the provider's %v", err, fe.Err)
+	}
+}
+
+func TestExplorerSourceSwitch(t *testing.T) {
+	fe := NewFakeExplorer()
+	m, _ := newTestManager(t, WithExplorer(fe))
+	tests := []struct {
+		database, player string
+		want             ExplorerQuery
+	}{
+		{ExplorerMasters, "", ExplorerQuery{Database: ExplorerMasters}},
+		// The player's games are those against the repertoire's color.
+		{ExplorerPlayer, "someone", ExplorerQuery{Database: ExplorerPlayer, Player: "someone", Color: "black"}},
+		{"", "", ExplorerQuery{Database: ExplorerLichess}},
+	}
+	for _, tt := range tests {
+		if err := m.SetExplorerSource(tt.database, tt.player); err != nil {
+			t.Fatal(err)
+		}
+		calls := len(fe.Calls)
+		if _, err := m.GetCurrentWinrates(); err != nil {
+			t.Fatal(err)
+		}
+		// Each source has its own cache entries, so the switch reaches the provider.
+		if len(fe.Calls) != calls+1 {
+			t.Fatalf("source %q: %d explorer calls, want 1", tt.database, len(fe.Calls)-calls)
+		}
+		q := fe.Queries[len(fe.Queries)-1]
+		if q.Database != tt.want.Database || q.Player != tt.want.Player || q.Color != tt.want.Color {
+			t.Errorf("source %q %q queried %+v, want %+v", tt.database, tt.player, q, tt.want)
+		}
+	}
+	if err := m.SetExplorerSource("chess.com", ""); err == nil {
+		t.Error("unknown explorer database accepted")
+	}
+	if q, err := m.GetExplorerSource(); err != nil || q.Database != ExplorerLichess {
+		t.Errorf("source = %+v, %v after a rejected switch, want lichess kept", q, err)
+	}
+}
+
+func TestExplorerProviders(t *testing.T) {
+	db, err := Open("file:" + filepath.Join(t.TempDir(), "repertoire.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	fe := NewFakeExplorer()
+	remote := NewRepertoireManager(db.SQL, WithExplorer(fe))
+	t.Cleanup(remote.shutdown)
+	if remote.cache == nil || remote.explorer != remote.cache || remote.cache.upstream != fe {
+		t.Error("remote provider not behind the cache")
+	}
+	if m := NewRepertoireManager(db.SQL, WithExplorer(fe), WithExplorerCache(CacheConfig{})); m.explorer != fe {
+		t.Error("provider cached with caching disabled")
+	}
+
+	m := NewRepertoireManager(db.SQL, WithExplorer(NewLocalExplorer(db.SQL)))
+	t.Cleanup(m.shutdown)
+	if m.cache != nil {
+		t.Fatal("local explorer behind the cache")
+	}
+	repID, err := m.Create("Main", "white", 1500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SelectRepertoire(repID)
+	games := `[Result "1-0"]
+
+1. e4 e5 1-0
+
+[Result "0-1"]
+
+1. e4 c5 0-1
+
+[Result "1/2-1/2"]
+
+1. d4 d5 1/2-1/2
+`
+	if n, err := m.ImportExplorerGames(games); err != nil || n != 3 {
+		t.Fatalf("imported %d games, err %v; want 3", n, err)
+	}
+	pos, err := m.GetCurrentWinrates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos.Total != 3 || len(pos.Moves) != 2 || pos.Moves[0].SAN != "e4" || !near(pos.Moves[0].Chance, 200.0/3) {
+		t.Errorf("local winrates = %+v, want 3 games with e4 in two of them", pos)
+	}
+
+	// Newly indexed games show at once.
+	if _, err := m.ImportExplorerGames("[Result \"1-0\"]\n\n1. d4 Nf6 1-0\n"); err != nil {
+		t.Fatal(err)
+	}
+	if pos, err := m.GetCurrentWinrates(); err != nil || pos.Total != 4 {
+		t.Errorf("winrates after another import: %d games, err %v; want 4", pos.Total, err)
+	}
+	if len(fe.Calls) != 0 {
+		t.Errorf("remote provider asked %d times while exploring locally", len(fe.Calls))
+	}
+}
@@ -0,0 +1,304 @@
+package backend
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/notnil/chess"
+)
+
+// IntegrityReport lists graph invariants a repertoire violates.
+type IntegrityReport struct {
+	MissingNodes     []string `json:"missingNodes"`     // positions referenced by an edge but absent from nodes
+	OrphanNodes      []string `json:"orphanNodes"`      // positions no move leads to that are not roots
+	MismatchedEdges  []Edge   `json:"mismatchedEdges"`  // the move does not lead from parent to child
+	UnreachableNodes []string `json:"unreachableNodes"` // positions that cannot be reached from any root, orphans included
+	Repaired         bool     `json:"repaired"`
+}
+
+// OK reports whether no problems were found.
+func (r IntegrityReport) OK() bool {
+	return len(r.MissingNodes) == 0 && len(r.OrphanNodes) == 0 &&
+		len(r.MismatchedEdges) == 0 && len(r.UnreachableNodes) == 0
+}
+
+// CheckIntegrity scans a repertoire for broken graph invariants. Reachability is
+// measured from the start position and the recorded roots, such as the [FEN] root of
+// an imported game, so a subtree cut loose from its line, or a position annotated
+// while browsing, is unreachable; the position at its top is also an orphan. With
+// repair set, missing nodes are created, mismatched edges are relabelled with the move
+// that does connect the two positions (or else pointed at where their move leads, or
+// dropped if it is illegal), and unreachable positions are deleted along with their
+// moves, all in one transaction.
+func (m *RepertoireManager) CheckIntegrity(repID int64, repair bool) (IntegrityReport, error) {
+	ctx, done := m.beginOperation()
+	defer done()
+
+	var report IntegrityReport
+	err := m.inTx(ctx, func(tx *sql.Tx) error {
+		g, err := loadGraph(ctx, tx, repID)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range g.edges {
+			for _, fen := range []string{e.ParentFEN, e.ChildFEN} {
+				if !g.nodes[fen] {
+					report.MissingNodes = appendUnique(report.MissingNodes, fen)
+				}
+			}
+			if err := checkContext(ctx); err != nil {
+				return err
+			}
+			if want, err := ApplyMoveSAN(e.ParentFEN, e.MoveSAN); err != nil || positionKey(want) != e.ChildFEN {
+				report.MismatchedEdges = append(report.MismatchedEdges, e)
+			}
+		}
+		incoming := make(map[string]bool)
+		for _, e := range g.edges {
+			incoming[e.ChildFEN] = true
+		}
+		reachable := g.reachable(g.roots()...)
+		for fen := range g.nodes {
+			if reachable[fen] {
+				continue
+			}
+			report.UnreachableNodes = append(report.UnreachableNodes, fen)
+			if !incoming[fen] {
+				report.OrphanNodes = append(report.OrphanNodes, fen)
+			}
+		}
+		sort.Strings(report.UnreachableNodes)
+		sort.Strings(report.OrphanNodes)
+
+		// Orphans are unreachable too, so they are repaired with them.
+		if !repair || len(report.MissingNodes)+len(report.MismatchedEdges)+len(report.UnreachableNodes) == 0 {
+			return nil
+		}
+		if err := repairGraph(ctx, tx, repID, report); err != nil {
+			return fmt.Errorf("failed to repair repertoire: %w", err)
+		}
+		report.Repaired = true
+		return nil
+	})
+	if err != nil {
+		return IntegrityReport{}, err
+	}
+	return report, nil
+}
+
+// repairGraph fixes what CheckIntegrity found. Reachability is recomputed after the
+// edge fixes, since a corrected edge may reconnect a subtree.
+func repairGraph(ctx context.Context, tx *sql.Tx, repID int64, report IntegrityReport) error {
+	insertNode := func(fen string) error {
+		_, err := tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO nodes (fen, rep_id, display_fen, sr_index, due, last_review) VALUES (?, ?, ?, 0, NULL, NULL)`,
+			fen, repID, fen)
+		return err
+	}
+	for _, fen := range report.MissingNodes {
+		if err := insertNode(fen); err != nil {
+			return err
+		}
+	}
+	for _, e := range report.MismatchedEdges {
+		if san, ok := moveBetween(e.ParentFEN, e.ChildFEN); ok {
+			_, err := tx.ExecContext(ctx,
+				`UPDATE edges SET move = ? WHERE rep_id = ? AND parent_fen = ? AND child_fen = ?`,
+				san, repID, e.ParentFEN, e.ChildFEN)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		_, err := tx.ExecContext(ctx,
+			`DELETE FROM edges WHERE rep_id = ? AND parent_fen = ? AND child_fen = ?`,
+			repID, e.ParentFEN, e.ChildFEN)
+		if err != nil {
+			return err
+		}
+		childFEN, err := ApplyMoveSAN(e.ParentFEN, e.MoveSAN)
+		if err != nil {
+			continue
+		}
+		childKey := positionKey(childFEN)
+		if err := insertNode(childKey); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO edges (rep_id, parent_fen, child_fen, move, kind) VALUES (?, ?, ?, ?, ?)`,
+			repID, e.ParentFEN, childKey, e.MoveSAN, e.Kind)
+		if err != nil {
+			return err
+		}
+	}
+
+	g, err := loadGraph(ctx, tx, repID)
+	if err != nil {
+		return err
+	}
+	reachable := g.reachable(g.roots()...)
+	for fen := range g.nodes {
+		if reachable[fen] {
+			continue
+		}
+		if err := checkContext(ctx); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx,
+			`DELETE FROM edges WHERE rep_id = ? AND (parent_fen = ? OR child_fen = ?)`, repID, fen, fen)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM nodes WHERE rep_id = ? AND fen = ?`, repID, fen)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// repGraph is an in-memory copy of a repertoire's nodes and edges, keyed by position key.
+type repGraph struct {
+	nodes    map[string]bool
+	display  map[string]string  // position key -> full FEN to show
+	reach    map[string]float64 // position key -> probability of reaching it, 0 if unknown
+	root     map[string]bool    // positions recorded as roots besides the start
+	edges    []Edge
+	children map[string][]Edge
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func loadGraph(ctx context.Context, q queryer, repID int64) (*repGraph, error) {
+	g := &repGraph{
+		nodes:    make(map[string]bool),
+		display:  make(map[string]string),
+		reach:    make(map[string]float64),
+		root:     make(map[string]bool),
+		children: make(map[string][]Edge),
+	}
+
+	rows, err := q.QueryContext(ctx, `SELECT fen, display_fen, COALESCE(reach, 0), root FROM nodes WHERE rep_id = ?`, repID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var fen, display string
+		var reach float64
+		var root bool
+		if err := rows.Scan(&fen, &display, &reach, &root); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		g.nodes[fen] = true
+		g.display[fen] = display
+		g.reach[fen] = reach
+		if root && fen != startKey {
+			g.root[fen] = true
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = q.QueryContext(ctx,
+		`SELECT parent_fen, child_fen, move, kind FROM edges WHERE rep_id = ? ORDER BY rowid`, repID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := Edge{RepID: repID}
+		if err := rows.Scan(&e.ParentFEN, &e.ChildFEN, &e.MoveSAN, &e.Kind); err != nil {
+			return nil, err
+		}
+		g.edges = append(g.edges, e)
+		g.children[e.ParentFEN] = append(g.children[e.ParentFEN], e)
+	}
+	return g, rows.Err()
+}
+
+// displayFEN returns the full FEN to show for a position key.
+func (g *repGraph) displayFEN(key string) string {
+	if fen := g.display[key]; fen != "" {
+		return fen
+	}
+	return key
+}
+
+// roots returns the start position followed by the other recorded roots, such as the
+// [FEN] root of an imported game, in order. Every walk of the repertoire starts there.
+func (g *repGraph) roots() []string {
+	others := make([]string, 0, len(g.root))
+	for fen := range g.root {
+		others = append(others, fen)
+	}
+	sort.Strings(others)
+	return append([]string{startKey}, others...)
+}
+
+// markRoot records a position of the repertoire as a root, so walks start there as
+// they do from the start position.
+func markRoot(ctx context.Context, tx *sql.Tx, repID int64, fen string) error {
+	if fen == startKey {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, `UPDATE nodes SET root = 1 WHERE rep_id = ? AND fen = ?`, repID, fen)
+	if err != nil {
+		return fmt.Errorf("failed to record root: %w", err)
+	}
+	return nil
+}
+
+// reachable returns every position reachable from the roots by following edges.
+func (g *repGraph) reachable(roots ...string) map[string]bool {
+	seen := make(map[string]bool, len(roots))
+	queue := make([]string, 0, len(roots))
+	for _, root := range roots {
+		if !seen[root] {
+			seen[root] = true
+			queue = append(queue, root)
+		}
+	}
+	for len(queue) > 0 {
+		fen := queue[0]
+		queue = queue[1:]
+		for _, e := range g.children[fen] {
+			if !seen[e.ChildFEN] {
+				seen[e.ChildFEN] = true
+				queue = append(queue, e.ChildFEN)
+			}
+		}
+	}
+	return seen
+}
+
+// moveBetween finds the legal move leading from parentFEN to the position keyed childFEN, in SAN.
+func moveBetween(parentFEN, childFEN string) (string, bool) {
+	pos, err := positionFromFEN(parentFEN)
+	if err != nil {
+		return "", false
+	}
+	for _, mv := range pos.ValidMoves() {
+		if positionKey(pos.Update(mv).String()) == childFEN {
+			return chess.AlgebraicNotation{}.Encode(pos, mv), true
+		}
+	}
+	return "", false
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, x := range list {
+		if x == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,164 @@
+package backend
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// newTestManager returns a manager on a fresh database, using the fake explorer unless
+// opts say otherwise, with a new white repertoire selected.
+func newTestManager(t *testing.T, opts ...ManagerOption) (*RepertoireManager, int64) {
+	t.Helper()
+	db, err := Open("file:" + filepath.Join(t.TempDir(), "repertoire.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewRepertoireManager(db.SQL, append([]ManagerOption{WithExplorer(NewFakeExplorer())}, opts...)...)
+	t.Cleanup(func() {
+		m.shutdown()
+		db.Close()
+	})
+	repID, err := m.Create("Main", "white", 1500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SelectRepertoire(repID)
+	return m, repID
+}
+
+// addLine stores the moves of line from the start position, following moves already
+// stored, and returns to the start position.
+func addLine(t *testing.T, m *RepertoireManager, line ...string) {
+	t.Helper()
+	m.SetCurrentFEN(StartFEN)
+	for _, san := range line {
+		stored, err := m.ListEdges()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if slices.Contains(stored, san) {
+			err = m.PlayMoveSAN(san)
+		} else {
+			err = m.AddEdge(san)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", san, err)
+		}
+	}
+	m.SetCurrentFEN(StartFEN)
+}
+
+// nodeExists reports whether the repertoire stores the position of fen.
+func nodeExists(t *testing.T, m *RepertoireManager, repID int64, fen string) bool {
+	t.Helper()
+	var n int
+	err := m.db.QueryRow(`SELECT COUNT(*) FROM nodes WHERE rep_id = ? AND fen = ?`, repID, positionKey(fen)).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n > 0
+}
+
+func keys(fens ...string) []string {
+	out := make([]string, len(fens))
+	for i, fen := range fens {
+		out[i] = positionKey(fen)
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestCheckIntegrityFindsCutSubtree(t *testing.T) {
+	m, repID := newTestManager(t)
+	addLine(t, m, "e4", "e5", "Nf3", "Nc6", "Bb5")
+	addLine(t, m, "e4", "c5")
+	line := playLine(t, "e4", "e5", "Nf3", "Nc6", "Bb5")
+	sicilian := playLine(t, "e4", "c5")
+
+	// Cut 2.Nf3 alone, leaving the rest of the line without a way to reach it.
+	_, err := m.db.Exec(`DELETE FROM edges WHERE rep_id = ? AND parent_fen = ? AND move = 'Nf3'`,
+		repID, positionKey(line[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := m.CheckIntegrity(repID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := keys(line[2]); !slices.Equal(report.OrphanNodes, want) {
+		t.Errorf("orphans = %q, want %q", report.OrphanNodes, want)
+	}
+	if want := keys(line[2:]...); !slices.Equal(report.UnreachableNodes, want) {
+		t.Errorf("unreachable = %q, want %q", report.UnreachableNodes, want)
+	}
+	if report.Repaired || !nodeExists(t, m, repID, line[4]) {
+		t.Error("check without repair changed the repertoire")
+	}
+
+	report, err = m.CheckIntegrity(repID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Repaired || len(report.UnreachableNodes) != 3 {
+		t.Errorf("repair report = %+v, want 3 unreachable positions repaired", report)
+	}
+	for _, fen := range line[2:] {
+		if nodeExists(t, m, repID, fen) {
+			t.Errorf("unreachable position %s kept", fen)
+		}
+	}
+	for _, fen := range []string{StartFEN, line[0], line[1], sicilian[1]} {
+		if !nodeExists(t, m, repID, fen) {
+			t.Errorf("reachable position %s deleted", fen)
+		}
+	}
+	if report, err := m.CheckIntegrity(repID, false); err != nil || !report.OK() {
+		t.Errorf("after repair: %+v, %v", report, err)
+	}
+}
+
+func TestCheckIntegrityKeepsRecordedRoots(t *testing.T) {
+	m, repID := newTestManager(t)
+	addLine(t, m, "e4", "e5")
+	_, err := m.ImportPGN(repID, `[Event "Ruy Lopez"]
+[SetUp "1"]
+[FEN "r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3"]
+
+3. Bb5 a6 4. Ba4 *`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d4 := playLine(t, "d4")[0]
+	m.SetCurrentFEN(d4)
+	if err := m.AddEdge("Nf6"); err != nil {
+		t.Fatal(err)
+	}
+	c4 := playLine(t, "c4")[0]
+	m.SetCurrentFEN(c4)
+	if err := m.SetPositionAnnotation(Annotation{Comment: "English"}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := m.CheckIntegrity(repID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := keys(c4)
+	if !slices.Equal(report.OrphanNodes, want) || !slices.Equal(report.UnreachableNodes, want) {
+		t.Errorf("orphans %q, unreachable %q, want only the annotated position %q",
+			report.OrphanNodes, report.UnreachableNodes, want)
+	}
+	if _, err := m.CheckIntegrity(repID, true); err != nil {
+		t.Fatal(err)
+	}
+	if nodeExists(t, m, repID, c4) {
+		t.Error("annotated position outside the repertoire kept")
+	}
+	for _, fen := range []string{"r1bqkbnr/pppp1ppp/2n5/1B2p3/4P3/5N2/PPPP1PPP/RNBQK2R b KQkq - 3 3", d4} {
+		if !nodeExists(t, m, repID, fen) {
+			t.Errorf("line from root %s deleted", fen)
+		}
+	}
+}
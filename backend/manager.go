@@ -4,53 +4,139 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 )
 
 type RepertoireManager struct {
 	db          *sql.DB
+	explorer    ExplorerProvider
+	cache       *ExplorerCache // nil when caching is disabled
+	cacheConfig CacheConfig
+	now         func() time.Time // clock used for scheduling; injectable for tests
+	engines     EngineLauncher
+	events      func(name string, data any) // overrides the Wails runtime for events
+
+	// mu guards the fields below; Wails calls the bound methods concurrently.
+	mu          sync.Mutex
 	selectedRep int64
 	currentFEN  string
+	source      *explorerSource // overrides the repertoire's explorer database while browsing
+	appCtx      context.Context // as given to Startup, for emitting events
+	ctx         context.Context // application context, see Startup
+	cancel      context.CancelFunc
+	posCtx      context.Context // cancelled when the current position changes
+	posCancel   context.CancelFunc
+	shownAt     time.Time                  // when the current position was reached, for response times
+	ops         map[int]context.CancelFunc // running long operations
+	nextOp      int
+
+	trainMu sync.Mutex       // taken before mu
+	session *trainingSession // nil when not training
+
+	engineMu  sync.Mutex
+	engine    *Engine // nil when no engine is running
+	engineCfg EngineConfig
+	analysis  *analysisRun // nil when the engine is idle
 }
 
-func NewRepertoireManager(db *sql.DB) *RepertoireManager {
-	startFEN := "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
-	return &RepertoireManager{
+// ManagerOption customises a RepertoireManager at construction time.
+type ManagerOption func(*RepertoireManager)
+
+// WithExplorer sets the provider used for opening statistics (Lichess by default).
+func WithExplorer(p ExplorerProvider) ManagerOption {
+	return func(m *RepertoireManager) { m.explorer = p }
+}
+
+// WithClock replaces the clock used to schedule and select reviews.
+func WithClock(now func() time.Time) ManagerOption {
+	return func(m *RepertoireManager) { m.now = now }
+}
+
+// WithExplorerCache sets how explorer results are cached; a zero TTL disables caching.
+func WithExplorerCache(cfg CacheConfig) ManagerOption {
+	return func(m *RepertoireManager) { m.cacheConfig = cfg }
+}
+
+// WithEngineLauncher replaces how analysis engines are started, e.g. with a FakeEngine.
+func WithEngineLauncher(l EngineLauncher) ManagerOption {
+	return func(m *RepertoireManager) { m.engines = l }
+}
+
+// WithEvents sends frontend events to emit instead of the Wails runtime.
+func WithEvents(emit func(name string, data any)) ManagerOption {
+	return func(m *RepertoireManager) { m.events = emit }
+}
+
+func NewRepertoireManager(db *sql.DB, opts ...ManagerOption) *RepertoireManager {
+	m := &RepertoireManager{
 		db:          db,
+		explorer:    NewLichessExplorer(),
+		cacheConfig: DefaultCacheConfig,
+		now:         time.Now,
+		engines:     LaunchEngine,
 		selectedRep: 1,        // no repertoire selected yet
-		currentFEN:  startFEN, // ✅ default starting position
+		currentFEN:  StartFEN, // ✅ default starting position
+		ops:         make(map[int]context.CancelFunc),
+	}
+	for _, opt := range opts {
+		opt(m)
 	}
+	// Local statistics are already on disk and change as games are indexed.
+	if _, local := m.explorer.(*LocalExplorer); !local && m.cacheConfig.TTL > 0 {
+		m.cache = NewExplorerCache(db, m.explorer, m.cacheConfig)
+		m.cache.base = m.baseContext
+		m.explorer = m.cache
+	}
+	return m
 }
 
-// Create a new repertoire
-func (m *RepertoireManager) Create(name, color string, elo int) (int64, error) {
-	// Insert repertoire row
-	res, err := m.db.ExecContext(context.Background(),
-		`INSERT INTO repertoire (name, color, elo, coverage) VALUES (?, ?, ?, 0.0)`,
-		name, color, elo)
+// inTx runs fn in a transaction, committing only if it returns nil.
+func (m *RepertoireManager) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, err
+		return canceledError(err)
 	}
-	repID, err := res.LastInsertId()
-	if err != nil {
-		return 0, err
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return canceledError(err)
 	}
+	return canceledError(tx.Commit())
+}
 
-	// Insert the start node (initial chess position FEN)
-	startFEN := "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
-	_, err = m.db.ExecContext(context.Background(),
-		`INSERT INTO nodes (fen, rep_id, sr_index, due, last_review) VALUES (?, ?, 0, NULL, NULL)`,
-		startFEN, repID)
+// Create a new repertoire
+func (m *RepertoireManager) Create(name, color string, elo int) (int64, error) {
+	var repID int64
+	err := m.inTx(m.baseContext(), func(tx *sql.Tx) error {
+		// Insert repertoire row
+		res, err := tx.Exec(
+			`INSERT INTO repertoire (name, color, elo, coverage) VALUES (?, ?, ?, 0.0)`,
+			name, color, elo)
+		if err != nil {
+			return err
+		}
+		repID, err = res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		// Insert the start node (initial chess position FEN)
+		_, err = tx.Exec(
+			`INSERT INTO nodes (fen, rep_id, display_fen, sr_index, due, last_review) VALUES (?, ?, ?, 0, NULL, NULL)`,
+			startKey, repID, StartFEN)
+		return err
+	})
 	if err != nil {
 		return 0, err
 	}
-
 	return repID, nil
 }
 
 // List all repertoires
 func (m *RepertoireManager) List() ([]Repertoire, error) {
-	rows, err := m.db.QueryContext(context.Background(),
-		`SELECT id, name, color, elo, coverage FROM repertoire ORDER BY id DESC`)
+	rows, err := m.db.QueryContext(m.baseContext(),
+		`SELECT id, name, color, elo, coverage, explorer_query, scheduler FROM repertoire ORDER BY id DESC`)
 	if err != nil {
 		return nil, err
 	}
@@ -58,9 +144,15 @@ func (m *RepertoireManager) List() ([]Repertoire, error) {
 	reps := make([]Repertoire, 0)
 	for rows.Next() {
 		var r Repertoire
-		if err := rows.Scan(&r.ID, &r.Name, &r.Color, &r.Elo, &r.Coverage); err != nil {
+		var query string
+		if err := rows.Scan(&r.ID, &r.Name, &r.Color, &r.Elo, &r.Coverage, &query, &r.Scheduler); err != nil {
 			return nil, err
 		}
+		q, err := decodeExplorerQuery(query)
+		if err != nil {
+			return nil, err
+		}
+		r.Explorer = q.withDefaults(r.Elo)
 		reps = append(reps, r)
 	}
 	return reps, rows.Err()
@@ -68,7 +160,7 @@ func (m *RepertoireManager) List() ([]Repertoire, error) {
 
 // Update an existing repertoire
 func (m *RepertoireManager) Update(r Repertoire) error {
-	_, err := m.db.ExecContext(context.Background(),
+	_, err := m.db.ExecContext(m.baseContext(),
 		`UPDATE repertoire SET name=?, color=?, elo=?, coverage=? WHERE id=?`,
 		r.Name, r.Color, r.Elo, r.Coverage, r.ID)
 	return err
@@ -76,55 +168,68 @@ func (m *RepertoireManager) Update(r Repertoire) error {
 
 // Delete a repertoire
 func (m *RepertoireManager) Delete(id int64) error {
-	_, err := m.db.ExecContext(context.Background(),
+	_, err := m.db.ExecContext(m.baseContext(),
 		`DELETE FROM repertoire WHERE id=?`, id)
 	return err
 }
 
 // Set the currently selected repertoire ID
 func (m *RepertoireManager) SetCurrentID(id int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.selectedRep = id
 }
 
 // Get the currently selected repertoire ID
 func (m *RepertoireManager) GetCurrentID() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.selectedRep
 }
 
 // Select a repertoire and set its start node
 func (m *RepertoireManager) SelectRepertoire(id int64) {
+	m.trainMu.Lock()
+	defer m.trainMu.Unlock()
+	m.session = nil
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.selectedRep = id
-	m.currentFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+	m.setFENLocked(StartFEN)
+	m.source = nil
 }
 
 // Get current selected repertoire ID
 func (m *RepertoireManager) GetSelectedID() int64 {
-	return m.selectedRep
+	return m.GetCurrentID()
 }
 
 // Get current FEN
 func (m *RepertoireManager) GetCurrentFEN() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.currentFEN
 }
 
 // Set current FEN (e.g. after extending)
 func (m *RepertoireManager) SetCurrentFEN(fen string) {
-	m.currentFEN = fen
+	m.setFEN(fen)
 }
 
 func (m *RepertoireManager) GetCurrentWinrates() (PositionWinrate, error) {
-	fen := m.currentFEN
-	if fen == "" {
-		return PositionWinrate{}, fmt.Errorf("no current FEN set")
-
+	// Take the context first so a move made meanwhile cancels this request.
+	ctx := m.positionContext()
+	_, fen, err := m.currentPosition()
+	if err != nil {
+		return PositionWinrate{}, err
 	}
-	elo, err := m.GetCurrentElo()
+	query, err := m.currentExplorerQuery(ctx)
 	if err != nil {
-		return PositionWinrate{}, fmt.Errorf("failed to get current elo: %w", err)
+		return PositionWinrate{}, canceledError(err)
 	}
-	data, err := FetchExplorerData(fen, elo)
+	data, err := m.explorer.Explore(ctx, fen, query)
 	if err != nil {
-		return PositionWinrate{}, err
+		return PositionWinrate{}, fmt.Errorf("failed to fetch explorer data: %w", canceledError(err))
 	}
 
 	pos := PositionWinrate{}
@@ -161,86 +266,132 @@ func (m *RepertoireManager) GetCurrentWinrates() (PositionWinrate, error) {
 	return pos, nil
 }
 
+// ImportExplorerGames indexes decided games from pgn into the local explorer database.
+func (m *RepertoireManager) ImportExplorerGames(pgn string) (int, error) {
+	ctx, done := m.beginOperation()
+	defer done()
+	n, err := NewLocalExplorer(m.db).AddPGN(ctx, pgn)
+	return n, canceledError(err)
+}
+
 func (m *RepertoireManager) PlayMoveSAN(moveSAN string) error {
-	if m.selectedRep == 0 {
-		return fmt.Errorf("no repertoire selected")
-	}
-	if m.currentFEN == "" {
-		return fmt.Errorf("no current FEN set")
+	_, fen, err := m.currentPosition()
+	if err != nil {
+		return err
 	}
 
-	childFEN, err := ApplyMoveSAN(m.currentFEN, moveSAN)
+	childFEN, err := ApplyMoveSAN(fen, moveSAN)
 	if err != nil {
 		return err
 	}
 
-	m.currentFEN = childFEN
+	m.setFEN(childFEN)
 	return nil
 }
 
+// canonicalSAN returns move, in any notation ApplyMove accepts, as canonical SAN from
+// fen. Moves that are not legal there are returned unchanged, so lookups report them
+// as not found.
+func canonicalSAN(fen, move string) string {
+	if san, _, err := ApplyMove(fen, move); err == nil {
+		return san
+	}
+	return move
+}
+
 // Edge represents an outgoing move (edge) from a position in a repertoire.
 type Edge struct {
 	RepID     int64
 	ParentFEN string
 	ChildFEN  string
 	MoveSAN   string
+	Kind      string // EdgePrimary, EdgeAlternate, EdgeOpponent or EdgeDeprecated
 }
 
+// AddEdge stores moveSAN from the current position and advances to the resulting position.
+// The parent and child nodes, the edge and the parent's due date are written atomically.
+// Positions are stored by key, so a move that transposes joins the existing node, and
+// the move is stored in canonical SAN whichever notation it was given in. A parent no
+// move leads to, reached by browsing, is recorded as a root of the repertoire.
 func (m *RepertoireManager) AddEdge(moveSAN string) error {
-	if m.selectedRep == 0 {
-		return fmt.Errorf("no repertoire selected")
-	}
-	if m.currentFEN == "" {
-		return fmt.Errorf("no current FEN set")
-	}
-	childFEN, err := ApplyMoveSAN(m.currentFEN, moveSAN)
+	repID, fen, err := m.currentPosition()
 	if err != nil {
 		return err
 	}
-
-	// Insert child node into `nodes` table (ignore if already exists)
-	_, err = m.db.ExecContext(context.Background(),
-		`INSERT OR IGNORE INTO nodes (fen, rep_id, sr_index, due, last_review) VALUES (?, ?, 0, NULL, NULL)`,
-		childFEN, m.selectedRep)
+	moveSAN, childFEN, err := ApplyMove(fen, moveSAN)
 	if err != nil {
-		return fmt.Errorf("failed to insert child node: %w", err)
+		return err
 	}
-
-	// Insert edge into `edges` table
-	_, err = m.db.ExecContext(context.Background(),
-		`INSERT INTO edges (rep_id, parent_fen, child_fen, move) VALUES (?, ?, ?, ?)`,
-		m.selectedRep, m.currentFEN, childFEN, moveSAN)
+	parentKey, childKey := positionKey(fen), positionKey(childFEN)
+	ctx := m.baseContext()
+	color, err := m.repertoireColor(ctx, repID)
 	if err != nil {
-		return fmt.Errorf("failed to insert edge: %w", err)
+		return err
 	}
 
-	// Update parent node's deadline to current time and reset sr_index to 0
-	_, err = m.db.ExecContext(context.Background(),
-		`UPDATE nodes SET due = CURRENT_TIMESTAMP, sr_index = 0 WHERE rep_id = ? AND fen = ?`,
-		m.selectedRep, m.currentFEN)
+	err = m.inTx(ctx, func(tx *sql.Tx) error {
+		// Both endpoints must exist as nodes; the parent may have been reached by browsing.
+		for _, pos := range []string{fen, childFEN} {
+			_, err := tx.Exec(
+				`INSERT OR IGNORE INTO nodes (fen, rep_id, display_fen, sr_index, due, last_review) VALUES (?, ?, ?, 0, NULL, NULL)`,
+				positionKey(pos), repID, pos)
+			if err != nil {
+				return fmt.Errorf("failed to insert node: %w", err)
+			}
+		}
+
+		// A position reached by browsing that no move leads to starts a line of its own.
+		var incoming int
+		err := tx.QueryRow(`SELECT COUNT(1) FROM edges WHERE rep_id = ? AND child_fen = ?`,
+			repID, parentKey).Scan(&incoming)
+		if err != nil {
+			return err
+		}
+		if incoming == 0 {
+			if err := markRoot(ctx, tx, repID, parentKey); err != nil {
+				return err
+			}
+		}
+
+		kind, err := newEdgeKind(ctx, tx, repID, color, parentKey)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			`INSERT INTO edges (rep_id, parent_fen, child_fen, move, kind) VALUES (?, ?, ?, ?, ?)`,
+			repID, parentKey, childKey, moveSAN, kind)
+		if err != nil {
+			return fmt.Errorf("failed to insert edge: %w", err)
+		}
+
+		// Update parent node's deadline to current time and reset sr_index to 0
+		_, err = tx.Exec(
+			`UPDATE nodes SET due = ?, sr_index = 0 WHERE rep_id = ? AND fen = ?`,
+			m.nowSQL(), repID, parentKey)
+		if err != nil {
+			return fmt.Errorf("failed to update parent node: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update parent node: %w", err)
+		return err
 	}
 
 	// Advance current position to the child (consistent with PlayMoveSAN behavior)
-	if err := m.PlayMoveSAN(moveSAN); err != nil {
-		return fmt.Errorf("failed to play move after adding edge: %w", err)
-	}
+	m.setFEN(childFEN)
 	return nil
 }
 
 // ListEdges returns SAN moves (strings) from the current position in the selected repertoire.
 func (m *RepertoireManager) ListEdges() ([]string, error) {
-	if m.selectedRep == 0 {
-		return nil, fmt.Errorf("no repertoire selected")
-	}
-	if m.currentFEN == "" {
-		return nil, fmt.Errorf("no current FEN set")
+	repID, fen, err := m.currentPosition()
+	if err != nil {
+		return nil, err
 	}
 
-	rows, err := m.db.QueryContext(context.Background(),
+	rows, err := m.db.QueryContext(m.baseContext(),
 		`SELECT move FROM edges WHERE rep_id = ? AND parent_fen = ?`,
-		m.selectedRep, m.currentFEN)
+		repID, positionKey(fen))
 	if err != nil {
 		return nil, err
 	}
@@ -260,193 +411,96 @@ func (m *RepertoireManager) ListEdges() ([]string, error) {
 	return moves, nil
 }
 
+// DeleteEdge removes moveSAN from the current position together with every position
+// that can only be reached through it, in one transaction; see DeleteSubtree.
 func (m *RepertoireManager) DeleteEdge(moveSAN string) error {
-	if m.selectedRep == 0 {
-		return fmt.Errorf("no repertoire selected")
-	}
-	if m.currentFEN == "" {
-		return fmt.Errorf("no current FEN set")
-	}
-
-	var childFEN string
-	err := m.db.QueryRowContext(context.Background(),
-		`SELECT child_fen FROM edges WHERE rep_id = ? AND parent_fen = ? AND move = ? LIMIT 1`,
-		m.selectedRep, m.currentFEN, moveSAN).Scan(&childFEN)
-	if err == sql.ErrNoRows {
-		return fmt.Errorf("edge not found")
-	}
-	if err != nil {
-		return err
-	}
-
-	_, err = m.db.ExecContext(context.Background(),
-		`DELETE FROM edges WHERE rep_id = ? AND parent_fen = ? AND move = ?`,
-		m.selectedRep, m.currentFEN, moveSAN)
-	if err != nil {
-		return err
-	}
-
-	// Remove orphan child node if no other edges reference it (but avoid deleting the standard start position)
-	var cnt int
-	err = m.db.QueryRowContext(context.Background(),
-		`SELECT COUNT(1) FROM edges WHERE rep_id = ? AND child_fen = ?`,
-		m.selectedRep, childFEN).Scan(&cnt)
-	if err != nil {
-		return err
-	}
-	if cnt == 0 {
-		startFEN := "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
-		if childFEN != startFEN {
-			_, err = m.db.ExecContext(context.Background(),
-				`DELETE FROM nodes WHERE rep_id = ? AND fen = ?`,
-				m.selectedRep, childFEN)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	return nil
+	_, err := m.DeleteSubtree(moveSAN, false)
+	return err
 }
 
+// GetDueFENs lists the positions a training session would ask, the most likely to be
+// reached first.
 func (m *RepertoireManager) GetDueFENs() ([]string, error) {
-	if m.selectedRep == 0 {
-		return nil, fmt.Errorf("no repertoire selected")
-	}
-
-	// Query to fetch FENs of nodes with due date <= current time
-	rows, err := m.db.QueryContext(context.Background(),
-		`SELECT fen FROM nodes WHERE rep_id = ? AND due <= DATETIME('now')`,
-		m.selectedRep)
+	repID, err := m.selected()
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch due FENs: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
-
-	var fens []string
-	for rows.Next() {
-		var fen string
-		if err := rows.Scan(&fen); err != nil {
-			return nil, fmt.Errorf("failed to scan FEN: %w", err)
-		}
-		fens = append(fens, fen)
+	queue, err := m.trainingQueue(m.baseContext(), repID)
+	if err != nil {
+		return nil, err
 	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	sort.SliceStable(queue, func(i, j int) bool {
+		return queue[i].reach > queue[j].reach
+	})
+	fens := make([]string, len(queue))
+	for i, item := range queue {
+		fens[i] = item.display
 	}
-
 	return fens, nil
 }
 
-// CountDueNodes returns the number of due nodes for a given repertoire.
+// CountDueNodes returns the number of positions a training session of a repertoire
+// would ask: the due positions where the repertoire side is to move, as trainingQueue
+// finds them.
 func (m *RepertoireManager) CountDueNodes(repID int64) (int, error) {
-	var count int
-	err := m.db.QueryRowContext(context.Background(),
-		`SELECT COUNT(*) FROM nodes WHERE rep_id = ? AND due <= DATETIME('now')`,
-		repID).Scan(&count)
+	queue, err := m.trainingQueue(m.baseContext(), repID)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count due nodes: %w", err)
 	}
-	return count, nil
+	return len(queue), nil
 }
 
-// TestCurrentPosition validates the SAN move against the current position and updates the Leitner box.
-func (m *RepertoireManager) TestCurrentPosition(moveSAN string) error {
-	if m.selectedRep == 0 {
-		return fmt.Errorf("no repertoire selected")
-	}
-	if m.currentFEN == "" {
-		return fmt.Errorf("no current FEN set")
-	}
-
-	// Check if the moveSAN is a valid edge from the current FEN
-	var childFEN string
-	err := m.db.QueryRowContext(context.Background(),
-		`SELECT child_fen FROM edges WHERE rep_id = ? AND parent_fen = ? AND move = ?`,
-		m.selectedRep, m.currentFEN, moveSAN).Scan(&childFEN)
-	if err == sql.ErrNoRows {
-		// Incorrect move: demote Leitner box
-		_, updateErr := m.db.ExecContext(context.Background(),
-			`UPDATE nodes SET sr_index = MAX(sr_index - 1, 0) WHERE rep_id = ? AND fen = ?`,
-			m.selectedRep, m.currentFEN)
-		if updateErr != nil {
-			return fmt.Errorf("failed to demote Leitner box: %w", updateErr)
-		}
-		return fmt.Errorf("incorrect move, correct move is: %s", childFEN)
-	} else if err != nil {
-		return fmt.Errorf("failed to validate move: %w", err)
-	}
-
-	// Correct move: promote Leitner box
-	_, err = m.db.ExecContext(context.Background(),
-		`UPDATE nodes SET sr_index = MIN(sr_index + 1,3) WHERE rep_id = ? AND fen = ?`,
-		m.selectedRep, m.currentFEN)
+// TestCurrentPosition grades moveSAN as the answer in the current position and
+// reschedules the position with the repertoire's scheduler: a repertoire move is graded
+// good, any other move again. A wrong answer is reported in the result, not as an error.
+// After a correct answer the current position advances to the child.
+// Only positions where the repertoire side is to move can be tested.
+func (m *RepertoireManager) TestCurrentPosition(moveSAN string) (GradeResult, error) {
+	repID, fen, err := m.currentPosition()
 	if err != nil {
-		return fmt.Errorf("failed to promote Leitner box: %w", err)
+		return GradeResult{}, err
 	}
+	ctx := m.baseContext()
 
-	// Advance to the child position
-	m.currentFEN = childFEN
-	return nil
-}
-
-// TestCurrentPositionWithDueDate validates the SAN move against the current position, updates the Leitner box, and adjusts the due date.
-func (m *RepertoireManager) TestCurrentPositionWithDueDate(moveSAN string) error {
-	if m.selectedRep == 0 {
-		return fmt.Errorf("no repertoire selected")
+	color, err := m.repertoireColor(ctx, repID)
+	if err != nil {
+		return GradeResult{}, err
 	}
-	if m.currentFEN == "" {
-		return fmt.Errorf("no current FEN set")
+	if sideToMove(fen) != color {
+		return GradeResult{}, fmt.Errorf("it is not %s to move in this position", color)
 	}
 
-	// Check if the moveSAN is a valid edge from the current FEN
-	var childFEN string
-	err := m.db.QueryRowContext(context.Background(),
-		`SELECT child_fen FROM edges WHERE rep_id = ? AND parent_fen = ? AND move = ?`,
-		m.selectedRep, m.currentFEN, moveSAN).Scan(&childFEN)
-	if err == sql.ErrNoRows {
-		// Incorrect move: demote Leitner box and reset due date
-		_, updateErr := m.db.ExecContext(context.Background(),
-			`UPDATE nodes SET sr_index = MAX(sr_index - 1, 0), due = DATETIME('now', '+1 day') WHERE rep_id = ? AND fen = ?`,
-			m.selectedRep, m.currentFEN)
-		if updateErr != nil {
-			return fmt.Errorf("failed to demote Leitner box: %w", updateErr)
-		}
-		return fmt.Errorf("incorrect move")
-	} else if err != nil {
-		return fmt.Errorf("failed to validate move: %w", err)
-	}
-
-	// Correct move: promote Leitner box and adjust due date based on Leitner index
-	_, err = m.db.ExecContext(context.Background(),
-		`UPDATE nodes SET sr_index = sr_index + 1, 
-		 due = CASE 
-		   WHEN sr_index = 0 THEN DATETIME('now', '+1 day')
-		   WHEN sr_index = 1 THEN DATETIME('now', '+3 days')
-		   WHEN sr_index = 2 THEN DATETIME('now', '+1 week')
-		   ELSE DATETIME('now', '+3 weeks')
-		 END
-		WHERE rep_id = ? AND fen = ?`,
-		m.selectedRep, m.currentFEN)
+	res, childKey, err := m.gradeMove(ctx, repID, positionKey(fen), moveSAN)
 	if err != nil {
-		return fmt.Errorf("failed to promote Leitner box: %w", err)
+		return GradeResult{}, err
 	}
+	if res.Correct {
+		// Advance to the child position, keeping the move counters for display
+		childFEN, err := ApplyMoveSAN(fen, res.Primary)
+		if err != nil {
+			childFEN = childKey
+		}
+		m.setFEN(childFEN)
+	}
+	return res, nil
+}
 
-	// Advance to the child position
-	m.currentFEN = childFEN
-	return nil
+// TestCurrentPositionWithDueDate is kept for existing callers; TestCurrentPosition
+// now updates the due date itself.
+func (m *RepertoireManager) TestCurrentPositionWithDueDate(moveSAN string) (GradeResult, error) {
+	return m.TestCurrentPosition(moveSAN)
 }
 
 func (m *RepertoireManager) GetCurrentRepCoverage() (float64, error) {
-	if m.selectedRep == 0 {
-		return 0.0, fmt.Errorf("no repertoire selected")
+	repID, err := m.selected()
+	if err != nil {
+		return 0.0, err
 	}
 
 	var coverage float64
-	err := m.db.QueryRowContext(context.Background(),
+	err = m.db.QueryRowContext(m.baseContext(),
 		`SELECT coverage FROM repertoire WHERE id = ?`,
-		m.selectedRep).Scan(&coverage)
+		repID).Scan(&coverage)
 	if err != nil {
 		return 0.0, fmt.Errorf("failed to get repertoire coverage: %w", err)
 	}
@@ -455,14 +509,15 @@ func (m *RepertoireManager) GetCurrentRepCoverage() (float64, error) {
 
 // Added a method to get the current repertoire's elo rating.
 func (m *RepertoireManager) GetCurrentElo() (int, error) {
-	if m.selectedRep == 0 {
-		return 0, fmt.Errorf("no repertoire selected")
+	repID, err := m.selected()
+	if err != nil {
+		return 0, err
 	}
 
 	var elo int
-	err := m.db.QueryRowContext(context.Background(),
+	err = m.db.QueryRowContext(m.baseContext(),
 		`SELECT elo FROM repertoire WHERE id = ?`,
-		m.selectedRep).Scan(&elo)
+		repID).Scan(&elo)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get repertoire elo: %w", err)
 	}
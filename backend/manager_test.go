@@ -0,0 +1,70 @@
+package backend
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestConcurrentCalls exercises the bound methods from several goroutines, as the
+// frontend does; run it with -race. Errors are expected when one call changes the
+// position another is working on, so only the final state is checked.
+func TestConcurrentCalls(t *testing.T) {
+	m, repID := newTrainingManager(t)
+	other, err := m.Create("Other", "black", 1500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := []func(){
+		func() {
+			m.SetCurrentFEN(StartFEN)
+			m.PlayMoveSAN("e4")
+			m.PlayMoveSAN("e5")
+		},
+		func() { m.GetCurrentWinrates() },
+		func() { m.ListEdges() },
+		func() { m.GetPositionAnnotation() },
+		func() { m.TestCurrentPosition("e4") },
+		func() {
+			m.SetExplorerSource(ExplorerMasters, "")
+			m.GetExplorerSource()
+			m.SetExplorerSource("", "")
+		},
+		func() {
+			m.SelectRepertoire(other)
+			m.SelectRepertoire(repID)
+		},
+		func() {
+			m.StartTraining(RepliesUniform)
+			m.TrainingMove("e4")
+			m.GetTrainingState()
+			m.StopTraining()
+		},
+	}
+	var wg sync.WaitGroup
+	for _, call := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				call()
+			}
+		}()
+	}
+	wg.Wait()
+
+	m.SelectRepertoire(repID)
+	if m.GetCurrentID() != repID || m.GetCurrentFEN() != StartFEN {
+		t.Errorf("selected %d at %q, want %d at the start position", m.GetCurrentID(), m.GetCurrentFEN(), repID)
+	}
+	if m.GetTrainingState().Active {
+		t.Error("training still active after selecting a repertoire")
+	}
+	q, err := m.GetExplorerSource()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved, _ := m.GetExplorerQuery(); q.Database != saved.Database {
+		t.Errorf("explorer source = %+v after selecting a repertoire, want the saved %+v", q, saved)
+	}
+}
@@ -0,0 +1,318 @@
+package backend
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// migrations lists every schema change in order; version N is migrations[N-1].
+// Append new migrations at the end and never edit one that has shipped.
+// Databases created before versioning report version 0 and may already contain
+// some of these changes, so early migrations check before altering.
+var migrations = []migration{
+	{version: 1, name: "initial schema", up: migrateInitialSchema},
+	{version: 2, name: "explorer cache in stats", up: migrateStatsCache},
+	{version: 3, name: "local explorer moves", up: migrateLocalMoves},
+	{version: 4, name: "repertoire explorer query", up: migrateExplorerQuery},
+	{version: 5, name: "scheduler state", up: migrateSchedulerState},
+	{version: 6, name: "review log", up: migrateReviewLog},
+	{version: 7, name: "edge kinds", up: migrateEdgeKinds},
+	{version: 8, name: "annotations", up: migrateAnnotations},
+	{version: 9, name: "position keys", up: migratePositionKeys},
+	{version: 10, name: "node reach", up: migrateNodeReach},
+	{version: 11, name: "recorded roots", up: migrateRoots},
+}
+
+func migrateInitialSchema(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+    CREATE TABLE IF NOT EXISTS repertoire (
+      id       INTEGER PRIMARY KEY AUTOINCREMENT,
+      name     TEXT NOT NULL UNIQUE,
+      color    TEXT NOT NULL CHECK (color IN ('white','black')),
+      elo      INTEGER NOT NULL DEFAULT 1200,
+      coverage REAL NOT NULL DEFAULT 0.0
+    );
+    CREATE TABLE IF NOT EXISTS nodes (
+      fen         TEXT NOT NULL,
+      rep_id      INTEGER NOT NULL,
+      sr_index    INTEGER NOT NULL DEFAULT 0,
+      due         INTEGER,
+      last_review INTEGER,
+      PRIMARY KEY (fen, rep_id),
+      FOREIGN KEY (rep_id) REFERENCES repertoire(id) ON DELETE CASCADE
+    );
+    CREATE TABLE IF NOT EXISTS stats (
+      fen        TEXT PRIMARY KEY,
+      games      INTEGER NOT NULL DEFAULT 0,
+      white_win  REAL NOT NULL DEFAULT 0.0,
+      black_win  REAL NOT NULL DEFAULT 0.0,
+      draw       REAL NOT NULL DEFAULT 0.0,
+      moves      TEXT NOT NULL DEFAULT '[]'
+    );
+    CREATE TABLE IF NOT EXISTS edges (
+      rep_id     INTEGER NOT NULL,
+      parent_fen TEXT NOT NULL,
+      child_fen  TEXT NOT NULL,
+      move       TEXT NOT NULL,
+      PRIMARY KEY (rep_id, parent_fen, child_fen),
+      FOREIGN KEY (rep_id) REFERENCES repertoire(id) ON DELETE CASCADE
+    );
+    `)
+	return err
+}
+
+// migrateStatsCache rebuilds stats as the explorer cache. The original table was
+// keyed by FEN alone and never written to, so nothing is lost.
+func migrateStatsCache(tx *sql.Tx) error {
+	hasQuery, err := hasColumn(tx, "stats", "query")
+	if err != nil || hasQuery {
+		return err
+	}
+	_, err = tx.Exec(`
+    DROP TABLE IF EXISTS stats;
+    CREATE TABLE stats (
+      fen        TEXT NOT NULL,
+      query      TEXT NOT NULL DEFAULT '',
+      games      INTEGER NOT NULL DEFAULT 0,
+      white_win  INTEGER NOT NULL DEFAULT 0,
+      black_win  INTEGER NOT NULL DEFAULT 0,
+      draw       INTEGER NOT NULL DEFAULT 0,
+      moves      TEXT NOT NULL DEFAULT '[]',
+      opening    TEXT NOT NULL DEFAULT '{}',
+      fetched_at INTEGER NOT NULL DEFAULT 0,
+      PRIMARY KEY (fen, query)
+    );
+    `)
+	return err
+}
+
+func migrateLocalMoves(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+    CREATE TABLE IF NOT EXISTS local_moves (
+      fen    TEXT NOT NULL,
+      uci    TEXT NOT NULL,
+      san    TEXT NOT NULL,
+      white  INTEGER NOT NULL DEFAULT 0,
+      black  INTEGER NOT NULL DEFAULT 0,
+      draws  INTEGER NOT NULL DEFAULT 0,
+      PRIMARY KEY (fen, uci)
+    );
+    `)
+	return err
+}
+
+func migrateExplorerQuery(tx *sql.Tx) error {
+	has, err := hasColumn(tx, "repertoire", "explorer_query")
+	if err != nil || has {
+		return err
+	}
+	_, err = tx.Exec(`ALTER TABLE repertoire ADD COLUMN explorer_query TEXT NOT NULL DEFAULT ''`)
+	return err
+}
+
+// migrateSchedulerState adds the per-node state used by the SM-2 and FSRS schedulers
+// and the per-repertoire scheduler choice. Existing repertoires keep Leitner.
+func migrateSchedulerState(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+    ALTER TABLE nodes ADD COLUMN ease REAL NOT NULL DEFAULT 2.5;
+    ALTER TABLE nodes ADD COLUMN interval REAL NOT NULL DEFAULT 0;
+    ALTER TABLE nodes ADD COLUMN stability REAL NOT NULL DEFAULT 0;
+    ALTER TABLE nodes ADD COLUMN difficulty REAL NOT NULL DEFAULT 0;
+    ALTER TABLE nodes ADD COLUMN reps INTEGER NOT NULL DEFAULT 0;
+    ALTER TABLE nodes ADD COLUMN lapses INTEGER NOT NULL DEFAULT 0;
+    ALTER TABLE repertoire ADD COLUMN scheduler TEXT NOT NULL DEFAULT 'leitner';
+    `)
+	return err
+}
+
+func migrateReviewLog(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+    CREATE TABLE IF NOT EXISTS review_log (
+      id          INTEGER PRIMARY KEY AUTOINCREMENT,
+      rep_id      INTEGER NOT NULL,
+      fen         TEXT NOT NULL,
+      move        TEXT NOT NULL DEFAULT '',
+      correct     INTEGER NOT NULL,
+      grade       INTEGER NOT NULL,
+      response_ms INTEGER NOT NULL DEFAULT 0,
+      before      TEXT NOT NULL DEFAULT '{}',
+      after       TEXT NOT NULL DEFAULT '{}',
+      reviewed_at TEXT NOT NULL,
+      FOREIGN KEY (rep_id) REFERENCES repertoire(id) ON DELETE CASCADE
+    );
+    CREATE INDEX IF NOT EXISTS review_log_position ON review_log (rep_id, fen, reviewed_at);
+    `)
+	return err
+}
+
+// migrateEdgeKinds classifies existing moves: the other side's moves become opponent
+// replies, and of the repertoire side's moves in a position the first one added is primary.
+func migrateEdgeKinds(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+    ALTER TABLE edges ADD COLUMN kind TEXT NOT NULL DEFAULT 'primary';
+    UPDATE edges SET kind = 'opponent'
+     WHERE (CASE WHEN parent_fen LIKE '% w %' THEN 'white' ELSE 'black' END) <>
+           (SELECT color FROM repertoire WHERE id = edges.rep_id);
+    UPDATE edges SET kind = 'alternate'
+     WHERE kind = 'primary'
+       AND rowid <> (SELECT MIN(e.rowid) FROM edges e
+                      WHERE e.rep_id = edges.rep_id AND e.parent_fen = edges.parent_fen);
+    `)
+	return err
+}
+
+// migrateAnnotations adds comments, NAGs and board markup to positions and moves.
+// NAGs are stored as a comma-separated list of codes, arrows and squares in the
+// comma-separated [%cal]/[%csl] format.
+func migrateAnnotations(tx *sql.Tx) error {
+	for _, table := range []string{"nodes", "edges"} {
+		for _, col := range []string{"comment", "nags", "arrows", "squares"} {
+			_, err := tx.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + col + ` TEXT NOT NULL DEFAULT ''`)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// migratePositionKeys re-keys every stored position by positionKey so transpositions
+// share one node, keeping the FEN a node was first stored with in display_fen. Rows
+// that now collide are merged: nodes keep the earliest due date and fill in missing
+// annotations, local explorer counts are added up, and duplicate moves and cache
+// entries are dropped.
+func migratePositionKeys(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+    ALTER TABLE nodes ADD COLUMN display_fen TEXT NOT NULL DEFAULT '';
+    UPDATE nodes SET display_fen = fen;
+    `)
+	if err != nil {
+		return err
+	}
+	err = rekeyRows(tx, "nodes", []string{"fen"}, `
+    UPDATE nodes SET
+      due = CASE WHEN nodes.due IS NULL OR d.due < nodes.due THEN d.due ELSE nodes.due END,
+      comment = CASE WHEN nodes.comment = '' THEN d.comment ELSE nodes.comment END,
+      nags = CASE WHEN nodes.nags = '' THEN d.nags ELSE nodes.nags END,
+      arrows = CASE WHEN nodes.arrows = '' THEN d.arrows ELSE nodes.arrows END,
+      squares = CASE WHEN nodes.squares = '' THEN d.squares ELSE nodes.squares END
+    FROM (SELECT * FROM nodes WHERE rowid = ?2) AS d
+    WHERE nodes.fen = ?1 AND nodes.rep_id = d.rep_id`)
+	if err != nil {
+		return err
+	}
+	if err := rekeyRows(tx, "edges", []string{"parent_fen", "child_fen"}, ""); err != nil {
+		return err
+	}
+	if err := rekeyRows(tx, "review_log", []string{"fen"}, ""); err != nil {
+		return err
+	}
+	if err := rekeyRows(tx, "stats", []string{"fen"}, ""); err != nil {
+		return err
+	}
+	err = rekeyRows(tx, "local_moves", []string{"fen"}, `
+    UPDATE local_moves SET
+      white = local_moves.white + d.white,
+      black = local_moves.black + d.black,
+      draws = local_moves.draws + d.draws
+    FROM (SELECT * FROM local_moves WHERE rowid = ?2) AS d
+    WHERE local_moves.fen = ?1 AND local_moves.uci = d.uci`)
+	if err != nil {
+		return err
+	}
+
+	// Merged positions may have gained a second primary move or lost their only one.
+	_, err = tx.Exec(`
+    UPDATE edges SET kind = 'alternate'
+     WHERE kind = 'primary'
+       AND rowid <> (SELECT MIN(e.rowid) FROM edges e
+                      WHERE e.rep_id = edges.rep_id AND e.parent_fen = edges.parent_fen AND e.kind = 'primary');
+    UPDATE edges SET kind = 'primary'
+     WHERE kind = 'alternate'
+       AND rowid = (SELECT MIN(e.rowid) FROM edges e
+                     WHERE e.rep_id = edges.rep_id AND e.parent_fen = edges.parent_fen AND e.kind = 'alternate')
+       AND NOT EXISTS (SELECT 1 FROM edges e
+                        WHERE e.rep_id = edges.rep_id AND e.parent_fen = edges.parent_fen AND e.kind = 'primary');
+    `)
+	return err
+}
+
+// rekeyRows rewrites the FEN columns cols of table to position keys. A row whose new
+// keys collide with an existing row is deleted, after running merge, if set, with the
+// new keys followed by the old row's rowid as arguments.
+func rekeyRows(tx *sql.Tx, table string, cols []string, merge string) error {
+	rows, err := tx.Query(`SELECT rowid, ` + strings.Join(cols, ", ") + ` FROM ` + table)
+	if err != nil {
+		return err
+	}
+	var pending [][]any // new keys then rowid
+	for rows.Next() {
+		var id int64
+		fens := make([]string, len(cols))
+		dest := []any{&id}
+		for i := range fens {
+			dest = append(dest, &fens[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return err
+		}
+		args, changed := make([]any, 0, len(cols)+1), false
+		for _, fen := range fens {
+			key := positionKey(fen)
+			changed = changed || key != fen
+			args = append(args, key)
+		}
+		if changed {
+			pending = append(pending, append(args, id))
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	set := strings.Join(cols, " = ?, ") + " = ?"
+	for _, args := range pending {
+		res, err := tx.Exec(`UPDATE OR IGNORE `+table+` SET `+set+` WHERE rowid = ?`, args...)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			continue
+		}
+		if merge != "" {
+			if _, err := tx.Exec(merge, args...); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE rowid = ?`, args[len(args)-1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateNodeReach adds the probability of reaching each position from the start,
+// NULL until it is first computed.
+func migrateNodeReach(tx *sql.Tx) error {
+	_, err := tx.Exec(`ALTER TABLE nodes ADD COLUMN reach REAL`)
+	return err
+}
+
+// migrateRoots marks the positions repertoire walks start from besides the start
+// position. Earlier versions took every position no move leads to as a root, so
+// those with moves of their own, such as the [FEN] root of an imported game, are
+// marked to keep their lines.
+func migrateRoots(tx *sql.Tx) error {
+	if _, err := tx.Exec(`ALTER TABLE nodes ADD COLUMN root INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+    UPDATE nodes SET root = 1
+     WHERE fen <> ?
+       AND EXISTS (SELECT 1 FROM edges e WHERE e.rep_id = nodes.rep_id AND e.parent_fen = nodes.fen)
+       AND NOT EXISTS (SELECT 1 FROM edges e WHERE e.rep_id = nodes.rep_id AND e.child_fen = nodes.fen)`,
+		startKey)
+	return err
+}
@@ -0,0 +1,228 @@
+package backend
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// playLine returns the FEN after each move of line, from the start position.
+func playLine(t *testing.T, line ...string) []string {
+	t.Helper()
+	fens := make([]string, 0, len(line))
+	fen := StartFEN
+	for _, san := range line {
+		next, err := ApplyMoveSAN(fen, san)
+		if err != nil {
+			t.Fatalf("%s: %v", san, err)
+		}
+		fens = append(fens, next)
+		fen = next
+	}
+	return fens
+}
+
+func TestMigratePositionKeysMergesTranspositions(t *testing.T) {
+	db := openRaw(t, filepath.Join(t.TempDir(), "repertoire.db"))
+	for _, m := range migrations[:8] {
+		if err := applyMigration(db, m); err != nil {
+			t.Fatalf("migration %d: %v", m.version, err)
+		}
+	}
+
+	// 1.e4 e5 2.Nf3 Nc6 and 1.Nf3 e5 2.e4 Nc6 reach the same positions after White's
+	// second move and after 2...Nc6, but with different move counters and en passant
+	// squares, so before position keys they were stored as separate nodes.
+	a := playLine(t, "e4", "e5", "Nf3", "Nc6", "Bb5")
+	b := playLine(t, "Nf3", "e5", "e4", "Nc6", "Bc4")
+	if a[2] == b[2] || positionKey(a[2]) != positionKey(b[2]) {
+		t.Fatalf("test lines do not transpose with different FENs")
+	}
+
+	mustExec(t, db, `INSERT INTO repertoire (name, color, elo) VALUES ('Main', 'white', 1500)`)
+	insertNode := func(fen, due, comment string) {
+		t.Helper()
+		if _, err := db.Exec(`INSERT INTO nodes (fen, rep_id, due, comment) VALUES (?, 1, NULLIF(?, ''), ?)`,
+			fen, due, comment); err != nil {
+			t.Fatal(err)
+		}
+	}
+	insertNode(StartFEN, "", "")
+	for i, fen := range a {
+		due := ""
+		if i == 2 {
+			due = "2024-01-10 00:00:00"
+		}
+		insertNode(fen, due, "")
+	}
+	for i, fen := range b {
+		due, comment := "", ""
+		if i == 2 {
+			due, comment = "2024-01-05 00:00:00", "Main tabiya"
+		}
+		insertNode(fen, due, comment)
+	}
+	insertEdge := func(parent, child, move, kind string) {
+		t.Helper()
+		if _, err := db.Exec(`INSERT INTO edges (rep_id, parent_fen, child_fen, move, kind) VALUES (1, ?, ?, ?, ?)`,
+			parent, child, move, kind); err != nil {
+			t.Fatal(err)
+		}
+	}
+	insertEdge(StartFEN, a[0], "e4", EdgePrimary)
+	insertEdge(StartFEN, b[0], "Nf3", EdgeAlternate)
+	for _, line := range []struct {
+		fens  []string
+		moves []string
+	}{
+		{a, []string{"e4", "e5", "Nf3", "Nc6", "Bb5"}},
+		{b, []string{"Nf3", "e5", "e4", "Nc6", "Bc4"}},
+	} {
+		for i := 1; i < len(line.fens); i++ {
+			kind := EdgePrimary
+			if sideToMove(line.fens[i-1]) == "black" {
+				kind = EdgeOpponent
+			}
+			insertEdge(line.fens[i-1], line.fens[i], line.moves[i], kind)
+		}
+	}
+	mustExec(t, db,
+		`INSERT INTO local_moves (fen, uci, san, white, black, draws) VALUES ('`+a[2]+`', 'b8c6', 'Nc6', 3, 1, 1)`,
+		`INSERT INTO local_moves (fen, uci, san, white, black, draws) VALUES ('`+b[2]+`', 'b8c6', 'Nc6', 4, 2, 0)`,
+		`INSERT INTO local_moves (fen, uci, san, white, black, draws) VALUES ('`+b[2]+`', 'g8f6', 'Nf6', 1, 0, 0)`,
+		`INSERT INTO review_log (rep_id, fen, correct, grade, reviewed_at) VALUES (1, '`+a[3]+`', 1, 3, '2024-01-01 00:00:00')`,
+		`INSERT INTO review_log (rep_id, fen, correct, grade, reviewed_at) VALUES (1, '`+b[3]+`', 0, 1, '2024-01-02 00:00:00')`,
+	)
+
+	if err := migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	count := func(query string, args ...any) int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return n
+	}
+	tabiya, afterNc6 := positionKey(a[2]), positionKey(a[3])
+
+	if n := count(`SELECT COUNT(*) FROM nodes`); n != 9 {
+		t.Errorf("%d nodes, want 9 after merging two pairs of transpositions", n)
+	}
+	for _, key := range []string{tabiya, afterNc6} {
+		if n := count(`SELECT COUNT(*) FROM nodes WHERE fen = ?`, key); n != 1 {
+			t.Errorf("%d nodes for %s, want 1", n, key)
+		}
+	}
+	var due, comment, display string
+	err := db.QueryRow(`SELECT due, comment, display_fen FROM nodes WHERE fen = ?`, tabiya).Scan(&due, &comment, &display)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if due != "2024-01-05 00:00:00" {
+		t.Errorf("merged due = %q, want the earlier 2024-01-05 00:00:00", due)
+	}
+	if comment != "Main tabiya" {
+		t.Errorf("merged comment = %q, want it filled in from the other node", comment)
+	}
+	if display != a[2] && display != b[2] {
+		t.Errorf("display_fen = %q, want one of the original FENs", display)
+	}
+
+	if n := count(`SELECT COUNT(*) FROM edges WHERE parent_fen NOT IN (SELECT fen FROM nodes)
+	                OR child_fen NOT IN (SELECT fen FROM nodes)`); n != 0 {
+		t.Errorf("%d edges point to positions that are not nodes", n)
+	}
+	if n := count(`SELECT COUNT(*) FROM edges WHERE child_fen = ?`, tabiya); n != 2 {
+		t.Errorf("%d moves lead to the merged node, want 2 (2.Nf3 and 2.e4)", n)
+	}
+	if n := count(`SELECT COUNT(*) FROM edges WHERE parent_fen = ?`, tabiya); n != 1 {
+		t.Errorf("%d moves leave the merged node, want the duplicate 2...Nc6 dropped", n)
+	}
+	if n := count(`SELECT COUNT(*) FROM edges WHERE parent_fen = ?`, afterNc6); n != 2 {
+		t.Errorf("%d moves after 2...Nc6, want Bb5 and Bc4", n)
+	}
+	rows, err := db.Query(`SELECT parent_fen, SUM(kind = 'primary') FROM edges
+	                        WHERE kind IN ('primary', 'alternate') GROUP BY parent_fen`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var parent string
+		var primaries int
+		if err := rows.Scan(&parent, &primaries); err != nil {
+			t.Fatal(err)
+		}
+		if primaries != 1 {
+			t.Errorf("%d primary moves in %s, want 1", primaries, parent)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	var white, black, draws int
+	err = db.QueryRow(`SELECT white, black, draws FROM local_moves WHERE fen = ? AND uci = 'b8c6'`, tabiya).
+		Scan(&white, &black, &draws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if white != 7 || black != 3 || draws != 1 {
+		t.Errorf("merged Nc6 counts = %d/%d/%d, want 7/3/1", white, black, draws)
+	}
+	if n := count(`SELECT COUNT(*) FROM local_moves`); n != 2 {
+		t.Errorf("%d local moves, want 2", n)
+	}
+	if n := count(`SELECT COUNT(*) FROM review_log WHERE fen = ?`, afterNc6); n != 2 {
+		t.Errorf("%d reviews of the merged position, want 2", n)
+	}
+}
+
+func TestMigrateRootsKeepsImportedLines(t *testing.T) {
+	db := openRaw(t, filepath.Join(t.TempDir(), "repertoire.db"))
+	for _, m := range migrations[:10] {
+		if err := applyMigration(db, m); err != nil {
+			t.Fatalf("migration %d: %v", m.version, err)
+		}
+	}
+	e4 := playLine(t, "e4")
+	ruy := "r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3"
+	bb5, err := ApplyMoveSAN(ruy, "Bb5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	annotated := positionKey(playLine(t, "d4")[0])
+	mustExec(t, db, `INSERT INTO repertoire (name, color, elo) VALUES ('Main', 'white', 1500)`)
+	for _, fen := range []string{startKey, positionKey(e4[0]), positionKey(ruy), positionKey(bb5), annotated} {
+		mustExec(t, db, `INSERT INTO nodes (fen, rep_id, display_fen) VALUES ('`+fen+`', 1, '`+fen+`')`)
+	}
+	mustExec(t, db,
+		`INSERT INTO edges (rep_id, parent_fen, child_fen, move) VALUES (1, '`+startKey+`', '`+positionKey(e4[0])+`', 'e4')`,
+		`INSERT INTO edges (rep_id, parent_fen, child_fen, move) VALUES (1, '`+positionKey(ruy)+`', '`+positionKey(bb5)+`', 'Bb5')`,
+	)
+
+	if err := migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	rows, err := db.Query(`SELECT fen FROM nodes WHERE root = 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var roots []string
+	for rows.Next() {
+		var fen string
+		if err := rows.Scan(&fen); err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, fen)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 1 || roots[0] != positionKey(ruy) {
+		t.Errorf("roots = %q, want only the imported line's root %q", roots, positionKey(ruy))
+	}
+}
@@ -2,11 +2,13 @@
 package backend
 
 type Repertoire struct {
-    ID       int64   `json:"id"`
-    Name     string  `json:"name"`
-    Color    string  `json:"color"`    // "white" | "black"
-    Elo      int     `json:"elo"`
-    Coverage float64 `json:"coverage"`
+    ID        int64         `json:"id"`
+    Name      string        `json:"name"`
+    Color     string        `json:"color"`    // "white" | "black"
+    Elo       int           `json:"elo"`
+    Coverage  float64       `json:"coverage"`
+    Explorer  ExplorerQuery `json:"explorer"`
+    Scheduler string        `json:"scheduler"` // "leitner" | "sm2" | "fsrs"
 }
 
 // MoveWinrate is a simplified view of each move with totals, winrates, and chance
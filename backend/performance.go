@@ -0,0 +1,128 @@
+package backend
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MovePerformance is how one of the repertoire side's prepared moves scores per the explorer.
+type MovePerformance struct {
+	FEN           string  `json:"fen"` // position the move is played in
+	Move          string  `json:"move"`
+	Kind          string  `json:"kind"`
+	Reach         float64 `json:"reach"` // probability of reaching FEN, 0 to 1, as in UpdateReach
+	Games         int     `json:"games"`
+	Score         float64 `json:"score"`         // for the repertoire side, draws counting half, 0 to 1
+	DrawRate      float64 `json:"drawRate"`      // 0 to 1
+	PositionScore float64 `json:"positionScore"` // score of all moves played in FEN together
+	BestMove      string  `json:"bestMove"`      // best scoring move in FEN with at least as many games, if not this one
+	BestScore     float64 `json:"bestScore"`
+	BelowAverage  bool    `json:"belowAverage"` // scores worse than the moves played in FEN on average
+}
+
+// PerformanceReport rates a repertoire's prepared moves with explorer results.
+type PerformanceReport struct {
+	RepID         int64             `json:"repId"`
+	Elo           int               `json:"elo"`
+	ExpectedScore float64           `json:"expectedScore"` // reach-weighted score of the primary moves, 0 to 1
+	BelowAverage  int               `json:"belowAverage"`
+	Moves         []MovePerformance `json:"moves"` // most reached first
+}
+
+// GetPerformanceReport looks up every primary and alternate move of a repertoire in the
+// explorer, at the repertoire's Elo and explorer settings, and compares its score with
+// the other moves played in the same position. The expected score averages the primary
+// moves' scores weighted by how likely their positions are to be reached from the start
+// position and the recorded roots, as in UpdateReach; positions cut off from them, and
+// moves the explorer has no games for, are left out of it.
+func (m *RepertoireManager) GetPerformanceReport(repID int64) (PerformanceReport, error) {
+	ctx, done := m.beginOperation()
+	defer done()
+
+	var elo int
+	err := m.db.QueryRowContext(ctx, `SELECT elo FROM repertoire WHERE id = ?`, repID).Scan(&elo)
+	if err != nil {
+		return PerformanceReport{}, fmt.Errorf("failed to get repertoire elo: %w", canceledError(err))
+	}
+	color, err := m.repertoireColor(ctx, repID)
+	if err != nil {
+		return PerformanceReport{}, canceledError(err)
+	}
+	q, err := m.repertoireExplorerQuery(ctx, repID)
+	if err != nil {
+		return PerformanceReport{}, canceledError(err)
+	}
+	g, err := loadGraph(ctx, m.db, repID)
+	if err != nil {
+		return PerformanceReport{}, canceledError(err)
+	}
+	g.withoutDeprecated()
+	w, err := m.computeReach(ctx, g, color, q)
+	if err != nil {
+		return PerformanceReport{}, canceledError(err)
+	}
+
+	report := PerformanceReport{RepID: repID, Elo: elo, Moves: []MovePerformance{}}
+	var weighted, weight float64
+	for fen, edges := range g.children {
+		if sideToMove(fen) != color || len(edges) == 0 {
+			continue
+		}
+		if err := checkContext(ctx); err != nil {
+			return PerformanceReport{}, err
+		}
+		display := g.displayFEN(fen)
+		played, err := m.moveFrequencies(ctx, display, q)
+		if err != nil {
+			return PerformanceReport{}, canceledError(err)
+		}
+		stats := map[string]moveFrequency{}
+		var all moveFrequency
+		for _, mv := range played {
+			stats[mv.SAN] = mv
+			all.White += mv.White
+			all.Black += mv.Black
+			all.Draws += mv.Draws
+			all.Games += mv.Games
+		}
+
+		for _, e := range edges {
+			p := MovePerformance{FEN: display, Move: e.MoveSAN, Kind: e.Kind, Reach: w.prob[fen]}
+			if mv, ok := stats[e.MoveSAN]; ok {
+				p.Games = mv.Games
+				p.Score = mv.score(color)
+				p.DrawRate = float64(mv.Draws) / float64(mv.Games)
+				p.PositionScore = all.score(color)
+				p.BelowAverage = p.Score < p.PositionScore
+				for _, alt := range played {
+					if alt.SAN != e.MoveSAN && alt.Games >= mv.Games && alt.score(color) > p.Score &&
+						alt.score(color) > p.BestScore {
+						p.BestMove, p.BestScore = alt.SAN, alt.score(color)
+					}
+				}
+				if e.Kind == EdgePrimary && p.Reach > 0 {
+					weighted += p.Reach * p.Score
+					weight += p.Reach
+				}
+			}
+			if p.BelowAverage {
+				report.BelowAverage++
+			}
+			report.Moves = append(report.Moves, p)
+		}
+	}
+	if weight > 0 {
+		report.ExpectedScore = weighted / weight
+	}
+	sort.SliceStable(report.Moves, func(i, j int) bool {
+		a, b := report.Moves[i], report.Moves[j]
+		if a.Reach != b.Reach {
+			return a.Reach > b.Reach
+		}
+		if a.FEN != b.FEN {
+			return a.FEN < b.FEN
+		}
+		return a.Move < b.Move
+	})
+	return report, nil
+}
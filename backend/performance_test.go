@@ -0,0 +1,48 @@
+package backend
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPerformanceReportWeightsByReach(t *testing.T) {
+	fe := NewFakeExplorer()
+	m, repID := newTestManager(t, WithExplorer(fe))
+	addLine(t, m, "e4", "e5", "Nf3")
+	addLine(t, m, "d4", "d5", "c4")
+	line := playLine(t, "e4", "e5")
+	qg := playLine(t, "d4", "d5")
+	fe.Responses[StartFEN] = ExplorerResponse{White: 110, Black: 80, Draws: 10, Moves: []Move{
+		{SAN: "e4", White: 60, Black: 30, Draws: 10}, // scores 0.65 for White
+		{SAN: "d4", White: 50, Black: 50},
+	}}
+	fe.Responses[line[0]] = explorerMoves(map[string]int{"e5": 50, "c5": 50})
+	fe.Responses[line[1]] = ExplorerResponse{White: 40, Black: 40, Draws: 20, Moves: []Move{
+		{SAN: "Nf3", White: 40, Black: 40, Draws: 20}, // scores 0.5, reached half the time
+	}}
+	fe.Responses[qg[1]] = ExplorerResponse{Black: 100, Moves: []Move{{SAN: "c4", Black: 100}}}
+
+	// Cut 1.d4 alone: what is left of the line must not weigh on the expected score.
+	_, err := m.db.Exec(`DELETE FROM edges WHERE rep_id = ? AND parent_fen = ? AND move = 'd4'`, repID, startKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := m.GetPerformanceReport(repID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (1*0.65 + 0.5*0.5) / 1.5; math.Abs(report.ExpectedScore-want) > 1e-9 {
+		t.Errorf("expected score = %v, want %v", report.ExpectedScore, want)
+	}
+	if len(report.Moves) != 3 {
+		t.Fatalf("moves = %+v, want e4, Nf3 and the cut-off c4", report.Moves)
+	}
+	first := report.Moves[0]
+	if first.Move != "e4" || first.Reach != 1 || math.Abs(first.Score-0.65) > 1e-9 || first.BelowAverage {
+		t.Errorf("first move = %+v, want e4 reached for certain scoring 0.65", first)
+	}
+	if last := report.Moves[2]; last.Move != "c4" || last.Reach != 0 {
+		t.Errorf("last move = %+v, want the cut-off c4 with no reach", last)
+	}
+}
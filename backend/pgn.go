@@ -0,0 +1,498 @@
+package backend
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PGNTag is a single `[Name "Value"]` header pair.
+type PGNTag struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// PGNMove is one half-move of a parsed game together with its annotations.
+// Variations are alternatives to this move, each starting from the position before it.
+// The first comment block after a move is its Comment; further blocks before the next
+// move are that move's PreComment, so "1. e4 {} {A} e5" puts A before e5.
+type PGNMove struct {
+	SAN        string       `json:"san"`
+	NAGs       []int        `json:"nags"`
+	PreComment string       `json:"preComment"` // comment written before the move, e.g. at the start of a variation
+	Comment    string       `json:"comment"`
+	Variations [][]*PGNMove `json:"variations"`
+}
+
+// PGNGame is one game from a PGN file with its full variation tree.
+type PGNGame struct {
+	Tags    []PGNTag   `json:"tags"`
+	Comment string     `json:"comment"` // comment before the first move
+	Moves   []*PGNMove `json:"moves"`   // main line
+	Result  string     `json:"result"`
+}
+
+// Tag returns the value of the named header, or "" when it is absent.
+func (g *PGNGame) Tag(name string) string {
+	for _, t := range g.Tags {
+		if t.Name == name {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+// suffixNAGs maps move suffix annotations to their numeric NAG codes.
+var suffixNAGs = map[string]int{
+	"!":  1,
+	"?":  2,
+	"!!": 3,
+	"??": 4,
+	"!?": 5,
+	"?!": 6,
+}
+
+// ParsePGN reads every game in r, keeping comments, NAGs and recursive variations.
+func ParsePGN(r io.Reader) ([]*PGNGame, error) {
+	p := &pgnParser{r: bufio.NewReader(r), line: 1, atLineStart: true}
+	var games []*PGNGame
+	for {
+		g, err := p.game()
+		if err != nil {
+			return nil, err
+		}
+		if g == nil {
+			return games, nil
+		}
+		games = append(games, g)
+	}
+}
+
+// pgnFrame is one open move sequence: the main line or a variation in progress.
+type pgnFrame struct {
+	owner      *PGNMove // move the variation is an alternative to; nil for the main line
+	moves      []*PGNMove
+	preComment string
+	commented  bool // the last move (or the game, before any move) already has its comment
+}
+
+// flushPreComment attaches a comment that no move followed to the last move.
+func (f *pgnFrame) flushPreComment() {
+	if f.preComment != "" && len(f.moves) > 0 {
+		last := f.moves[len(f.moves)-1]
+		last.Comment = joinComment(last.Comment, f.preComment)
+		f.preComment = ""
+	}
+}
+
+type pgnParser struct {
+	r           *bufio.Reader
+	line        int
+	atLineStart bool
+	peeked      *pgnToken
+}
+
+type pgnTokenKind int
+
+const (
+	tokEOF pgnTokenKind = iota
+	tokTag
+	tokComment
+	tokOpen
+	tokClose
+	tokNAG
+	tokResult
+	tokMove
+)
+
+type pgnToken struct {
+	kind  pgnTokenKind
+	text  string
+	value string // tag value for tokTag
+	line  int
+}
+
+func (p *pgnParser) errorf(line int, format string, args ...any) error {
+	return fmt.Errorf("pgn line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// game parses the next game, returning nil at end of input.
+func (p *pgnParser) game() (*PGNGame, error) {
+	g := &PGNGame{}
+	stack := []*pgnFrame{{}}
+	started := false
+	for {
+		tok, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		top := stack[len(stack)-1]
+		switch tok.kind {
+		case tokEOF:
+			if !started {
+				return nil, nil
+			}
+			if len(stack) > 1 {
+				return nil, p.errorf(tok.line, "unterminated variation")
+			}
+			top.flushPreComment()
+			g.Moves = stack[0].moves
+			return g, nil
+		case tokTag:
+			if started {
+				// A header after moves means the previous game had no result token.
+				if len(stack) > 1 {
+					return nil, p.errorf(tok.line, "unterminated variation")
+				}
+				top.flushPreComment()
+				g.Moves = stack[0].moves
+				return g, nil
+			}
+			g.Tags = append(g.Tags, PGNTag{Name: tok.text, Value: tok.value})
+		case tokComment:
+			started = true
+			switch {
+			case top.commented || (top.owner != nil && len(top.moves) == 0):
+				top.preComment = joinComment(top.preComment, tok.text)
+			case len(top.moves) > 0:
+				last := top.moves[len(top.moves)-1]
+				last.Comment = joinComment(last.Comment, tok.text)
+				top.commented = true
+			default:
+				g.Comment = joinComment(g.Comment, tok.text)
+				top.commented = true
+			}
+		case tokOpen:
+			started = true
+			if len(top.moves) == 0 {
+				return nil, p.errorf(tok.line, "variation before any move")
+			}
+			top.flushPreComment()
+			stack = append(stack, &pgnFrame{owner: top.moves[len(top.moves)-1]})
+		case tokClose:
+			if len(stack) == 1 {
+				return nil, p.errorf(tok.line, "unexpected ')'")
+			}
+			stack = stack[:len(stack)-1]
+			top.flushPreComment()
+			if len(top.moves) > 0 {
+				top.owner.Variations = append(top.owner.Variations, top.moves)
+			}
+		case tokNAG:
+			if len(top.moves) == 0 {
+				return nil, p.errorf(tok.line, "NAG %s before any move", tok.text)
+			}
+			n, err := strconv.Atoi(tok.text)
+			if err != nil {
+				return nil, p.errorf(tok.line, "invalid NAG $%s", tok.text)
+			}
+			last := top.moves[len(top.moves)-1]
+			last.NAGs = append(last.NAGs, n)
+		case tokResult:
+			if len(stack) > 1 {
+				return nil, p.errorf(tok.line, "result inside a variation")
+			}
+			p.peeked = nil
+			top.flushPreComment()
+			g.Result = tok.text
+			g.Moves = stack[0].moves
+			return g, nil
+		case tokMove:
+			started = true
+			mv := &PGNMove{SAN: tok.text, PreComment: top.preComment}
+			top.preComment = ""
+			top.commented = false
+			if n, ok := suffixNAGs[tok.value]; ok {
+				mv.NAGs = append(mv.NAGs, n)
+			}
+			top.moves = append(top.moves, mv)
+		}
+		p.peeked = nil
+	}
+}
+
+func joinComment(a, b string) string {
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+	return a + " " + b
+}
+
+func (p *pgnParser) peek() (*pgnToken, error) {
+	if p.peeked != nil {
+		return p.peeked, nil
+	}
+	tok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	p.peeked = tok
+	return tok, nil
+}
+
+func (p *pgnParser) readRune() (rune, bool) {
+	c, _, err := p.r.ReadRune()
+	if err != nil {
+		return 0, false
+	}
+	if c == '\n' {
+		p.line++
+		p.atLineStart = true
+	} else {
+		p.atLineStart = false
+	}
+	return c, true
+}
+
+func (p *pgnParser) unreadRune() {
+	_ = p.r.UnreadRune()
+}
+
+// readUntil consumes runes up to and including end, returning what came before it.
+func (p *pgnParser) readUntil(end rune) (string, bool) {
+	var sb strings.Builder
+	for {
+		c, ok := p.readRune()
+		if !ok {
+			return sb.String(), false
+		}
+		if c == end {
+			return sb.String(), true
+		}
+		sb.WriteRune(c)
+	}
+}
+
+func (p *pgnParser) next() (*pgnToken, error) {
+	for {
+		lineStart := p.atLineStart
+		c, ok := p.readRune()
+		if !ok {
+			return &pgnToken{kind: tokEOF, line: p.line}, nil
+		}
+		line := p.line
+		switch {
+		case c == '%' && lineStart:
+			// Escape mechanism: the rest of the line is ignored.
+			p.readUntil('\n')
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\uFEFF':
+		case c == '[':
+			return p.tag(line)
+		case c == '{':
+			text, ok := p.readUntil('}')
+			if !ok {
+				return nil, p.errorf(line, "unterminated comment")
+			}
+			return &pgnToken{kind: tokComment, text: strings.Join(strings.Fields(text), " "), line: line}, nil
+		case c == ';':
+			text, _ := p.readUntil('\n')
+			return &pgnToken{kind: tokComment, text: strings.TrimSpace(text), line: line}, nil
+		case c == '(':
+			return &pgnToken{kind: tokOpen, line: line}, nil
+		case c == ')':
+			return &pgnToken{kind: tokClose, line: line}, nil
+		case c == '$':
+			return &pgnToken{kind: tokNAG, text: p.word(), line: line}, nil
+		case c == '*':
+			return &pgnToken{kind: tokResult, text: "*", line: line}, nil
+		default:
+			p.unreadRune()
+			word := p.word()
+			if word == "" {
+				// Not a symbol character; skip it rather than looping forever.
+				p.readRune()
+				continue
+			}
+			if tok := p.symbol(word, line); tok != nil {
+				return tok, nil
+			}
+		}
+	}
+}
+
+// word reads a run of PGN symbol characters.
+func (p *pgnParser) word() string {
+	var sb strings.Builder
+	for {
+		c, ok := p.readRune()
+		if !ok {
+			return sb.String()
+		}
+		if strings.ContainsRune(" \t\r\n{}();[]$", c) {
+			if c == '\n' {
+				// Keep the newline so a following '%' is still seen at line start.
+				p.line--
+			}
+			p.unreadRune()
+			return sb.String()
+		}
+		sb.WriteRune(c)
+	}
+}
+
+// symbol classifies a bare word as a result, move number or move.
+func (p *pgnParser) symbol(word string, line int) *pgnToken {
+	switch word {
+	case "1-0", "0-1", "1/2-1/2":
+		return &pgnToken{kind: tokResult, text: word, line: line}
+	}
+	// Strip a leading move number such as "12." or "12..." (possibly glued to the move).
+	// Castling written with zeros starts with a digit too but is not a move number.
+	if !strings.HasPrefix(word, "0-0") {
+		word = strings.TrimLeft(word, "0123456789")
+		word = strings.TrimLeft(word, ".")
+	}
+	if word == "" {
+		return nil
+	}
+	san, suffix := splitSANSuffix(word)
+	if strings.HasPrefix(san, "0-0") {
+		san = strings.ReplaceAll(san, "0", "O")
+	}
+	return &pgnToken{kind: tokMove, text: san, value: suffix, line: line}
+}
+
+// splitSANSuffix separates trailing !/? annotations from a SAN move.
+func splitSANSuffix(word string) (string, string) {
+	i := len(word)
+	for i > 0 && (word[i-1] == '!' || word[i-1] == '?') {
+		i--
+	}
+	return word[:i], word[i:]
+}
+
+func (p *pgnParser) tag(line int) (*pgnToken, error) {
+	body, ok := p.readUntil(']')
+	if !ok {
+		return nil, p.errorf(line, "unterminated tag")
+	}
+	// The closing bracket may appear inside the quoted value; keep reading until quotes balance.
+	for strings.Count(strings.ReplaceAll(body, `\"`, ""), `"`)%2 == 1 {
+		more, ok := p.readUntil(']')
+		if !ok {
+			return nil, p.errorf(line, "unterminated tag")
+		}
+		body += "]" + more
+	}
+	body = strings.TrimSpace(body)
+	name, rest, _ := strings.Cut(body, " ")
+	rest = strings.TrimSpace(rest)
+	if name == "" || len(rest) < 2 || rest[0] != '"' || rest[len(rest)-1] != '"' {
+		return nil, p.errorf(line, "malformed tag [%s]", body)
+	}
+	value := rest[1 : len(rest)-1]
+	value = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(value)
+	return &pgnToken{kind: tokTag, text: name, value: value, line: line}, nil
+}
+
+// pgnLineWidth is the column at which exported movetext is wrapped.
+const pgnLineWidth = 80
+
+// WritePGN writes the game with its tags, comments, NAGs and nested variations.
+func WritePGN(w io.Writer, g *PGNGame) error {
+	var sb strings.Builder
+	for _, t := range g.Tags {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(t.Value)
+		fmt.Fprintf(&sb, "[%s \"%s\"]\n", t.Name, value)
+	}
+	if len(g.Tags) > 0 {
+		sb.WriteString("\n")
+	}
+
+	ply := 0
+	if fen := g.Tag("FEN"); fen != "" {
+		ply = fenPly(fen)
+	}
+	var toks []string
+	if g.Comment != "" || (len(g.Moves) > 0 && g.Moves[0].PreComment != "") {
+		// An empty game comment keeps the first move's comment from being read as one.
+		toks = append(toks, "{"+g.Comment+"}")
+	}
+	toks = appendPGNLine(toks, g.Moves, ply, true)
+	result := g.Result
+	if result == "" {
+		result = "*"
+	}
+	toks = append(toks, result)
+
+	col := 0
+	for _, tok := range toks {
+		if col > 0 && col+1+len(tok) > pgnLineWidth {
+			sb.WriteString("\n")
+			col = 0
+		} else if col > 0 {
+			sb.WriteString(" ")
+			col++
+		}
+		sb.WriteString(tok)
+		col += len(tok)
+	}
+	sb.WriteString("\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// appendPGNLine renders moves starting at ply (0 = white's first move) as movetext tokens.
+func appendPGNLine(toks []string, moves []*PGNMove, ply int, needNumber bool) []string {
+	for i, mv := range moves {
+		if mv.PreComment != "" {
+			toks = append(toks, "{"+mv.PreComment+"}")
+			needNumber = true
+		}
+		toks = append(toks, pgnMoveText(ply, mv.SAN, needNumber))
+		for _, n := range mv.NAGs {
+			toks = append(toks, fmt.Sprintf("$%d", n))
+		}
+		needNumber = false
+		if mv.Comment != "" || (i+1 < len(moves) && moves[i+1].PreComment != "") {
+			// An empty comment keeps the next move's comment from being read as this one's.
+			toks = append(toks, "{"+mv.Comment+"}")
+			needNumber = true
+		}
+		for _, v := range mv.Variations {
+			vt := appendPGNLine(nil, v, ply, true)
+			if len(vt) == 0 {
+				continue
+			}
+			vt[0] = "(" + vt[0]
+			vt[len(vt)-1] += ")"
+			toks = append(toks, vt...)
+			needNumber = true
+		}
+		ply++
+	}
+	return toks
+}
+
+// pgnMoveText prefixes san with its move number where PGN requires one.
+func pgnMoveText(ply int, san string, needNumber bool) string {
+	switch {
+	case ply%2 == 0:
+		return fmt.Sprintf("%d. %s", ply/2+1, san)
+	case needNumber:
+		return fmt.Sprintf("%d... %s", ply/2+1, san)
+	}
+	return san
+}
+
+// fenPly converts the side to move and fullmove number of a FEN into a ply index.
+func fenPly(fen string) int {
+	fields := strings.Fields(fen)
+	if len(fields) < 6 {
+		return 0
+	}
+	n, err := strconv.Atoi(fields[5])
+	if err != nil || n < 1 {
+		n = 1
+	}
+	ply := (n - 1) * 2
+	if fields[1] == "b" {
+		ply++
+	}
+	return ply
+}
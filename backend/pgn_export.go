@@ -0,0 +1,147 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ExportPGN renders a repertoire as a PGN study rooted at the start position, followed
+// by a game with SetUp and FEN tags for each recorded root the study does not reach,
+// such as a line imported from a [FEN] game. The first stored move from each position
+// is the main line and the others become variations; a position reached a second time
+// is written once with a comment pointing to the line where it was first shown. Move
+// annotations are written as a comment before the move, position annotations as the
+// comment after the move that first reaches the position.
+func (m *RepertoireManager) ExportPGN(repID int64) (string, error) {
+	ctx, done := m.beginOperation()
+	defer done()
+
+	var name string
+	err := m.db.QueryRowContext(ctx,
+		`SELECT name FROM repertoire WHERE id = ?`, repID).Scan(&name)
+	if err != nil {
+		return "", fmt.Errorf("failed to load repertoire: %w", canceledError(err))
+	}
+
+	g, err := loadGraph(ctx, m.db, repID)
+	if err != nil {
+		return "", canceledError(err)
+	}
+
+	ann, err := loadAnnotations(ctx, m.db, repID)
+	if err != nil {
+		return "", canceledError(err)
+	}
+
+	date := m.now().Format("2006.01.02")
+	ex := &pgnExporter{ctx: ctx, children: g.children, ann: ann, shown: make(map[string]string)}
+	var sb strings.Builder
+	games := 0
+	for _, root := range g.roots() {
+		// The start position is always written, so an empty repertoire still exports a game.
+		if _, shown := ex.shown[root]; shown || (root != startKey && len(g.children[root]) == 0) {
+			continue
+		}
+		games++
+		tags := []PGNTag{
+			{Name: "Event", Value: name},
+			{Name: "Site", Value: "?"},
+			{Name: "Date", Value: date},
+			{Name: "Round", Value: "-"},
+			{Name: "White", Value: "?"},
+			{Name: "Black", Value: "?"},
+			{Name: "Result", Value: "*"},
+		}
+		ply := 0
+		ex.shown[root] = "the starting position"
+		if root != startKey {
+			display := g.displayFEN(root)
+			tags = append(tags, PGNTag{Name: "SetUp", Value: "1"}, PGNTag{Name: "FEN", Value: display})
+			ply = fenPly(display)
+			ex.shown[root] = fmt.Sprintf("the starting position of game %d", games)
+		}
+		game := &PGNGame{
+			Tags:    tags,
+			Comment: ann.nodes[root].pgnComment(),
+			Moves:   ex.line(root, ply, nil),
+			Result:  "*",
+		}
+		if ex.err != nil {
+			return "", ex.err
+		}
+		if games > 1 {
+			sb.WriteString("\n")
+		}
+		if err := WritePGN(&sb, game); err != nil {
+			return "", err
+		}
+	}
+	return sb.String(), nil
+}
+
+// pgnExporter turns the edges graph into a PGN variation tree.
+type pgnExporter struct {
+	ctx      context.Context
+	err      error // set when the walk was cancelled
+	children map[string][]Edge
+	ann      *repAnnotations
+	shown    map[string]string // position key -> numbered line where it first appears, or which starting position it is
+}
+
+// line builds the main line from fen (at ply) with every alternative as a variation.
+// Positions are claimed in the order they are written, so transposition comments
+// always point backwards in the text.
+func (ex *pgnExporter) line(fen string, ply int, path []string) []*PGNMove {
+	var moves []*PGNMove
+	for {
+		if ex.err = checkContext(ex.ctx); ex.err != nil {
+			return moves
+		}
+		edges := ex.children[fen]
+		if len(edges) == 0 {
+			return moves
+		}
+		main, transposed := ex.move(edges[0], ply, path)
+		moves = append(moves, main)
+		for _, alt := range edges[1:] {
+			mv, altTransposed := ex.move(alt, ply, path)
+			v := []*PGNMove{mv}
+			if !altTransposed {
+				v = append(v, ex.line(alt.ChildFEN, ply+1, appendPath(path, ply, alt.MoveSAN))...)
+			}
+			main.Variations = append(main.Variations, v)
+		}
+		if transposed {
+			return moves
+		}
+		path = appendPath(path, ply, edges[0].MoveSAN)
+		fen = edges[0].ChildFEN
+		ply++
+	}
+}
+
+// move renders one edge and reports whether it transposes to a position already
+// written, in which case it gets a comment instead of a continuation.
+func (ex *pgnExporter) move(e Edge, ply int, path []string) (*PGNMove, bool) {
+	edgeAnn := ex.ann.edges[edgeKey{e.ParentFEN, e.MoveSAN}]
+	mv := &PGNMove{SAN: e.MoveSAN, NAGs: edgeAnn.NAGs, PreComment: edgeAnn.pgnComment()}
+	if first, ok := ex.shown[e.ChildFEN]; ok {
+		mv.Comment = transposeComment + first
+		return mv, true
+	}
+	ex.shown[e.ChildFEN] = strings.Join(appendPath(path, ply, e.MoveSAN), " ")
+	nodeAnn := ex.ann.nodes[e.ChildFEN]
+	mv.NAGs = append(mv.NAGs[:len(mv.NAGs):len(mv.NAGs)], nodeAnn.NAGs...)
+	mv.Comment = nodeAnn.pgnComment()
+	return mv, false
+}
+
+// transposeComment starts the comment ExportPGN writes on a transposing move;
+// ImportPGN skips such comments so they do not become position annotations.
+const transposeComment = "Transposes to "
+
+// appendPath extends a numbered move list such as ["1. e4", "e5"] with san played at ply.
+func appendPath(path []string, ply int, san string) []string {
+	return append(path[:len(path):len(path)], pgnMoveText(ply, san, len(path) == 0))
+}
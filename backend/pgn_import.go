@@ -0,0 +1,249 @@
+package backend
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// IllegalMove describes a PGN move that could not be played in its position.
+type IllegalMove struct {
+	Game int    `json:"game"` // 1-based index of the game in the PGN
+	FEN  string `json:"fen"`
+	SAN  string `json:"san"`
+	Line string `json:"line"` // moves leading to the position, space separated
+}
+
+// ImportResult summarises what a PGN import added to a repertoire.
+type ImportResult struct {
+	Games          int           `json:"games"`
+	NewPositions   int           `json:"newPositions"`
+	KnownPositions int           `json:"knownPositions"`
+	NewMoves       int           `json:"newMoves"`
+	KnownMoves     int           `json:"knownMoves"`
+	IllegalMoves   []IllegalMove `json:"illegalMoves"`
+}
+
+// ImportPGN replays every game in pgn, including all variations, and adds the
+// resulting positions and moves to the repertoire in a single transaction. The
+// position a game with a [FEN] tag starts from is recorded as a root.
+func (m *RepertoireManager) ImportPGN(repID int64, pgn string) (ImportResult, error) {
+	games, err := ParsePGN(strings.NewReader(pgn))
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("failed to parse PGN: %w", err)
+	}
+
+	ctx, done := m.beginOperation()
+	defer done()
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ImportResult{}, canceledError(err)
+	}
+	defer tx.Rollback()
+
+	var color string
+	err = tx.QueryRowContext(ctx, `SELECT color FROM repertoire WHERE id = ?`, repID).Scan(&color)
+	if err == sql.ErrNoRows {
+		return ImportResult{}, fmt.Errorf("repertoire %d not found", repID)
+	}
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	imp := &pgnImporter{
+		ctx:     ctx,
+		tx:      tx,
+		repID:   repID,
+		color:   color,
+		seen:    make(map[string]bool),
+		touched: make(map[string]bool),
+		result:  ImportResult{Games: len(games), IllegalMoves: []IllegalMove{}},
+	}
+	for i, g := range games {
+		imp.game = i + 1
+		startFEN := StartFEN
+		if fen := g.Tag("FEN"); fen != "" {
+			startFEN = fen
+		}
+		pos, err := positionFromFEN(startFEN)
+		if err != nil {
+			return ImportResult{}, fmt.Errorf("game %d: %w", i+1, err)
+		}
+		if err := imp.visit(pos.String()); err != nil {
+			return ImportResult{}, err
+		}
+		if err := markRoot(ctx, tx, repID, positionKey(pos.String())); err != nil {
+			return ImportResult{}, err
+		}
+		if err := imp.annotateNode(positionKey(pos.String()), parseComment(g.Comment)); err != nil {
+			return ImportResult{}, err
+		}
+		if err := imp.line(pos, g.Moves, nil); err != nil {
+			return ImportResult{}, canceledError(err)
+		}
+	}
+
+	if err := imp.scheduleTouched(m.nowSQL()); err != nil {
+		return ImportResult{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return ImportResult{}, canceledError(err)
+	}
+	return imp.result, nil
+}
+
+// pgnImporter carries the state of one ImportPGN transaction.
+type pgnImporter struct {
+	ctx     context.Context
+	tx      *sql.Tx
+	repID   int64
+	color   string
+	game    int
+	seen    map[string]bool // position keys already counted in this import
+	touched map[string]bool // parent positions that received a new edge
+	result  ImportResult
+}
+
+// visit inserts the node for fen once per import and counts it as new or known.
+func (imp *pgnImporter) visit(fen string) error {
+	key := positionKey(fen)
+	if imp.seen[key] {
+		return nil
+	}
+	imp.seen[key] = true
+	res, err := imp.tx.ExecContext(imp.ctx,
+		`INSERT OR IGNORE INTO nodes (fen, rep_id, display_fen, sr_index, due, last_review) VALUES (?, ?, ?, 0, NULL, NULL)`,
+		key, imp.repID, fen)
+	if err != nil {
+		return fmt.Errorf("failed to insert node: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		imp.result.NewPositions++
+	} else {
+		imp.result.KnownPositions++
+	}
+	return nil
+}
+
+// line replays moves from pos, recursing into each move's variations. The main
+// move is stored before its alternatives so edge order follows the PGN. An illegal
+// move ends its line, but its own variations are still replayed.
+func (imp *pgnImporter) line(pos *chess.Position, moves []*PGNMove, path []string) error {
+	for _, pm := range moves {
+		if err := checkContext(imp.ctx); err != nil {
+			return err
+		}
+		parentFEN := pos.String()
+		mv, san, err := decodeMove(pos, pm.SAN)
+		var next *chess.Position
+		if err != nil {
+			imp.result.IllegalMoves = append(imp.result.IllegalMoves, IllegalMove{
+				Game: imp.game,
+				FEN:  parentFEN,
+				SAN:  pm.SAN,
+				Line: strings.Join(path, " "),
+			})
+		} else {
+			next = pos.Update(mv)
+			if err := imp.visit(next.String()); err != nil {
+				return err
+			}
+			parentKey, childKey := positionKey(parentFEN), positionKey(next.String())
+			if err := imp.edge(parentKey, childKey, san); err != nil {
+				return err
+			}
+			if err := imp.annotate(parentKey, childKey, san, pm); err != nil {
+				return err
+			}
+		}
+
+		for _, v := range pm.Variations {
+			if err := imp.line(pos, v, path); err != nil {
+				return err
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		pos = next
+		path = append(path[:len(path):len(path)], san)
+	}
+	return nil
+}
+
+// scheduleTouched makes the parents that gained moves due for review, as with AddEdge.
+func (imp *pgnImporter) scheduleTouched(due string) error {
+	for fen := range imp.touched {
+		_, err := imp.tx.ExecContext(imp.ctx,
+			`UPDATE nodes SET due = ?, sr_index = 0 WHERE rep_id = ? AND fen = ?`,
+			due, imp.repID, fen)
+		if err != nil {
+			return fmt.Errorf("failed to update parent node: %w", err)
+		}
+	}
+	return nil
+}
+
+func (imp *pgnImporter) edge(parentFEN, childFEN, san string) error {
+	kind, err := newEdgeKind(imp.ctx, imp.tx, imp.repID, imp.color, parentFEN)
+	if err != nil {
+		return err
+	}
+	res, err := imp.tx.ExecContext(imp.ctx,
+		`INSERT OR IGNORE INTO edges (rep_id, parent_fen, child_fen, move, kind) VALUES (?, ?, ?, ?, ?)`,
+		imp.repID, parentFEN, childFEN, san, kind)
+	if err != nil {
+		return fmt.Errorf("failed to insert edge: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		imp.result.NewMoves++
+		imp.touched[parentFEN] = true
+	} else {
+		imp.result.KnownMoves++
+	}
+	return nil
+}
+
+// annotate stores the annotations of an imported move: the comment before it and
+// move NAGs go on the edge, the comment after it and other NAGs on the position it
+// reaches. Empty annotations leave existing ones alone.
+func (imp *pgnImporter) annotate(parentFEN, childFEN, san string, pm *PGNMove) error {
+	edgeAnn := parseComment(pm.PreComment)
+	var nodeAnn Annotation
+	if !strings.HasPrefix(pm.Comment, transposeComment) {
+		nodeAnn = parseComment(pm.Comment)
+	}
+	for _, n := range pm.NAGs {
+		if isMoveNAG(n) {
+			edgeAnn.NAGs = append(edgeAnn.NAGs, n)
+		} else {
+			nodeAnn.NAGs = append(nodeAnn.NAGs, n)
+		}
+	}
+	if !edgeAnn.Empty() {
+		_, err := imp.tx.ExecContext(imp.ctx,
+			`UPDATE edges SET comment = ?, nags = ?, arrows = ?, squares = ?
+			 WHERE rep_id = ? AND parent_fen = ? AND move = ?`,
+			append(annotationArgs(edgeAnn), imp.repID, parentFEN, san)...)
+		if err != nil {
+			return fmt.Errorf("failed to save annotation: %w", err)
+		}
+	}
+	return imp.annotateNode(childFEN, nodeAnn)
+}
+
+func (imp *pgnImporter) annotateNode(fen string, a Annotation) error {
+	if a.Empty() {
+		return nil
+	}
+	_, err := imp.tx.ExecContext(imp.ctx,
+		`UPDATE nodes SET comment = ?, nags = ?, arrows = ?, squares = ? WHERE rep_id = ? AND fen = ?`,
+		append(annotationArgs(a), imp.repID, fen)...)
+	if err != nil {
+		return fmt.Errorf("failed to save annotation: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,404 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// repertoireMoves lists the stored moves of a repertoire as "position key: move", sorted.
+func repertoireMoves(t *testing.T, m *RepertoireManager, repID int64) []string {
+	t.Helper()
+	g, err := loadGraph(context.Background(), m.db, repID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var moves []string
+	for parent, edges := range g.children {
+		for _, e := range edges {
+			moves = append(moves, fmt.Sprintf("%s: %s", parent, e.MoveSAN))
+		}
+	}
+	slices.Sort(moves)
+	return moves
+}
+
+// describeMoves renders a parsed line compactly: pre-comments in <>, comments in {},
+// NAGs as $n and variations in parentheses.
+func describeMoves(moves []*PGNMove) string {
+	var parts []string
+	for _, mv := range moves {
+		s := mv.SAN
+		if mv.PreComment != "" {
+			s = "<" + mv.PreComment + ">" + s
+		}
+		for _, n := range mv.NAGs {
+			s += fmt.Sprintf(" $%d", n)
+		}
+		if mv.Comment != "" {
+			s += " {" + mv.Comment + "}"
+		}
+		for _, v := range mv.Variations {
+			s += " (" + describeMoves(v) + ")"
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParsePGN(t *testing.T) {
+	tests := []struct {
+		name    string
+		pgn     string
+		want    []string // per game: tags, game comment, moves and result
+		wantErr string
+	}{
+		{
+			name: "main line",
+			pgn:  "[Event \"Test\"]\n[Result \"1-0\"]\n\n1. e4 e5 2. Nf3 1-0\n",
+			want: []string{`Event=Test Result=1-0 | {} | e4 e5 Nf3 | 1-0`},
+		},
+		{
+			name: "nested variations",
+			pgn:  "1. e4 (1. d4 d5 (1... Nf6 2. c4)) e5 2. Nf3 (2. Bc4) *",
+			want: []string{` | {} | e4 (d4 d5 (Nf6 c4)) e5 Nf3 (Bc4) | *`},
+		},
+		{
+			name: "NAGs and suffixes",
+			pgn:  "1. e4! $14 e5?! 2. Nf3!! d6?? 3. d4!? (3. Bc4? $2) *",
+			want: []string{` | {} | e4 $1 $14 e5 $6 Nf3 $3 d6 $4 d4 $5 (Bc4 $2 $2) | *`},
+		},
+		{
+			name: "comments",
+			pgn:  "{Intro} 1. e4 {best by test} {Black replies} e5 (1... c5 {Sicilian}) ; rest of line\n2. Nf3 {}\n*",
+			want: []string{` | {Intro} | e4 {best by test} <Black replies>e5 {rest of line} (c5 {Sicilian}) Nf3 | *`},
+		},
+		{
+			name: "comment at the start of a variation",
+			pgn:  "1. e4 ({Or} 1. d4 {closed}) e5 *",
+			want: []string{` | {} | e4 (<Or>d4 {closed}) e5 | *`},
+		},
+		{
+			name: "glued move numbers and zero castling",
+			pgn:  "1.e4 e5 2.Nf3 Nc6 3.Bc4 Bc5 4.0-0 0-0 5. 0-0-0 *",
+			want: []string{` | {} | e4 e5 Nf3 Nc6 Bc4 Bc5 O-O O-O O-O-O | *`},
+		},
+		{
+			name: "escaped tag value and escape lines",
+			pgn:  "[Event \"A \\\"quoted\\\" [name]\"]\n% ignored 1. d4\n1. e4 *",
+			want: []string{`Event=A "quoted" [name] | {} | e4 | *`},
+		},
+		{
+			name: "several games without results",
+			pgn:  "[Event \"One\"]\n1. e4\n\n[Event \"Two\"]\n1. d4 d5\n",
+			want: []string{`Event=One | {} | e4 | `, `Event=Two | {} | d4 d5 | `},
+		},
+		{name: "empty input", pgn: " \n"},
+
+		{name: "unterminated comment", pgn: "1. e4 {open", wantErr: "pgn line 1: unterminated comment"},
+		{name: "unterminated variation", pgn: "1. e4 (1. d4\n", wantErr: "pgn line 2: unterminated variation"},
+		{name: "unexpected close", pgn: "1. e4 ) e5 *", wantErr: "pgn line 1: unexpected ')'"},
+		{name: "variation before any move", pgn: "(1. d4) 1. e4 *", wantErr: "variation before any move"},
+		{name: "result inside a variation", pgn: "1. e4 (1. d4 *) e5", wantErr: "result inside a variation"},
+		{name: "NAG before any move", pgn: "$1 1. e4 *", wantErr: "NAG 1 before any move"},
+		{name: "malformed tag", pgn: "[Event Test]\n1. e4 *", wantErr: "malformed tag"},
+		{name: "unterminated tag", pgn: "[Event \"Test", wantErr: "unterminated tag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			games, err := ParsePGN(strings.NewReader(tt.pgn))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, g := range games {
+				var tags []string
+				for _, tag := range g.Tags {
+					tags = append(tags, tag.Name+"="+tag.Value)
+				}
+				got = append(got, fmt.Sprintf("%s | {%s} | %s | %s", strings.Join(tags, " "), g.Comment, describeMoves(g.Moves), g.Result))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestImportPGN(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  string // imported first
+		pgn     string
+		want    ImportResult
+		illegal []string // "game: line | move"
+		moves   []string // moves stored afterwards, as "line: move", sorted
+		roots   []string // recorded roots besides the start position, as lines
+		wantErr string
+	}{
+		{
+			name:  "variations",
+			pgn:   "1. e4 e5 (1... c5 2. Nf3) 2. Nf3 *",
+			want:  ImportResult{Games: 1, NewPositions: 5, KnownPositions: 1, NewMoves: 5},
+			moves: []string{": e4", "e4 c5: Nf3", "e4 e5: Nf3", "e4: c5", "e4: e5"},
+		},
+		{
+			name:  "transposition stored once",
+			pgn:   "1. Nf3 (1. c4 Nf6 2. Nf3) Nf6 2. c4 *",
+			want:  ImportResult{Games: 1, NewPositions: 5, KnownPositions: 1, NewMoves: 6},
+			moves: []string{": Nf3", ": c4", "Nf3 Nf6: c4", "Nf3: Nf6", "c4 Nf6: Nf3", "c4: Nf6"},
+		},
+		{
+			name:   "known moves",
+			before: "1. e4 e5 *",
+			pgn:    "1. e4 e5 (1... c5) 2. Nf3 *",
+			want:   ImportResult{Games: 1, NewPositions: 2, KnownPositions: 3, NewMoves: 2, KnownMoves: 2},
+			moves:  []string{": e4", "e4 e5: Nf3", "e4: c5", "e4: e5"},
+		},
+		{
+			name:  "several games",
+			pgn:   "[Event \"One\"]\n1. e4 e5 *\n\n[Event \"Two\"]\n1. d4 d5 *",
+			want:  ImportResult{Games: 2, NewPositions: 4, KnownPositions: 1, NewMoves: 4},
+			moves: []string{": d4", ": e4", "d4: d5", "e4: e5"},
+		},
+		{
+			name: "FEN game",
+			pgn: "[SetUp \"1\"]\n[FEN \"r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3\"]\n\n" +
+				"3. Bb5 a6 (3... Nf6) *",
+			want:  ImportResult{Games: 1, NewPositions: 4, NewMoves: 3},
+			moves: []string{"e4 e5 Nf3 Nc6 Bb5: Nf6", "e4 e5 Nf3 Nc6 Bb5: a6", "e4 e5 Nf3 Nc6: Bb5"},
+			roots: []string{"e4 e5 Nf3 Nc6"},
+		},
+		{
+			name:    "illegal moves",
+			pgn:     "1. e4 e5 2. Ke3 (2. Nf3 Nc6) Nc6 3. Nf3 *",
+			want:    ImportResult{Games: 1, NewPositions: 4, KnownPositions: 1, NewMoves: 4},
+			illegal: []string{"1: e4 e5 | Ke3"},
+			moves:   []string{": e4", "e4 e5 Nf3: Nc6", "e4 e5: Nf3", "e4: e5"},
+		},
+		{
+			name:    "malformed PGN",
+			pgn:     "1. e4 (1. d4",
+			wantErr: "failed to parse PGN: pgn line 1: unterminated variation",
+		},
+		{
+			name:    "invalid FEN",
+			pgn:     "1. e4 *\n\n[FEN \"8/8/8 w\"]\n1. e4 *",
+			wantErr: "game 2: invalid FEN",
+		},
+	}
+	// lines names the positions of the tests by the moves that reach them.
+	lines := make(map[string]string)
+	for _, line := range []string{"e4 e5 Nf3 Nc6 Bb5", "e4 c5 Nf3", "Nf3 Nf6 c4", "c4 Nf6 Nf3", "d4 d5"} {
+		moves := strings.Fields(line)
+		for i, fen := range playLine(t, moves...) {
+			lines[positionKey(fen)] = strings.Join(moves[:i+1], " ")
+		}
+	}
+	named := func(key string) string {
+		if key == startKey {
+			return ""
+		}
+		if line, ok := lines[key]; ok {
+			return line
+		}
+		return key
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, repID := newTestManager(t)
+			if tt.before != "" {
+				if _, err := m.ImportPGN(repID, tt.before); err != nil {
+					t.Fatal(err)
+				}
+			}
+			res, err := m.ImportPGN(repID, tt.pgn)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				if moves := repertoireMoves(t, m, repID); len(moves) != 0 {
+					t.Errorf("failed import stored moves %q", moves)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var illegal []string
+			for _, im := range res.IllegalMoves {
+				illegal = append(illegal, fmt.Sprintf("%d: %s | %s", im.Game, im.Line, im.SAN))
+			}
+			if !slices.Equal(illegal, tt.illegal) {
+				t.Errorf("illegal moves = %q, want %q", illegal, tt.illegal)
+			}
+			res.IllegalMoves = nil
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("result = %+v, want %+v", res, tt.want)
+			}
+
+			g, err := loadGraph(context.Background(), m.db, repID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var moves, roots []string
+			for parent, edges := range g.children {
+				for _, e := range edges {
+					moves = append(moves, named(parent)+": "+e.MoveSAN)
+				}
+			}
+			for _, root := range g.roots()[1:] {
+				roots = append(roots, named(root))
+			}
+			slices.Sort(moves)
+			if !slices.Equal(moves, tt.moves) {
+				t.Errorf("moves = %q, want %q", moves, tt.moves)
+			}
+			if !slices.Equal(roots, tt.roots) {
+				t.Errorf("roots = %q, want %q", roots, tt.roots)
+			}
+		})
+	}
+}
+
+func TestExportPGNRecordedRoots(t *testing.T) {
+	m, repID := newTestManager(t, WithClock(func() time.Time { return testNow }))
+	addLine(t, m, "e4", "e5", "Nf3")
+	// The first root is reached from the start position, the second is not.
+	_, err := m.ImportPGN(repID, `[SetUp "1"]
+[FEN "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2"]
+
+2... Nc6 *
+
+[SetUp "1"]
+[FEN "r1bqkbnr/pppp1ppp/2n5/1B2p3/4P3/5N2/PPPP1PPP/RNBQK2R b KQkq - 3 3"]
+
+3... a6 4. Ba4 *`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pgn, err := m.ExportPGN(repID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	games, err := ParsePGN(strings.NewReader(pgn))
+	if err != nil {
+		t.Fatalf("exported PGN does not parse: %v\n%s", err, pgn)
+	}
+	if len(games) != 2 {
+		t.Fatalf("exported %d games, want the study and one for the unreached root:\n%s", len(games), pgn)
+	}
+	for i, g := range games {
+		if g.Tag("Date") != "2024.03.01" {
+			t.Errorf("game %d dated %q, want the manager's clock", i+1, g.Tag("Date"))
+		}
+	}
+	if games[0].Tag("FEN") != "" {
+		t.Errorf("study starts from %q, want the start position", games[0].Tag("FEN"))
+	}
+	root := games[1]
+	if root.Tag("SetUp") != "1" || root.Tag("FEN") != "r1bqkbnr/pppp1ppp/2n5/1B2p3/4P3/5N2/PPPP1PPP/RNBQK2R b KQkq - 3 3" {
+		t.Errorf("root game tags = %+v, want SetUp and the root's FEN", root.Tags)
+	}
+	if !strings.Contains(pgn, "\n3... a6 4. Ba4 *\n") {
+		t.Errorf("root game not numbered from its FEN:\n%s", pgn)
+	}
+
+	copyID, err := m.Create("Copy", "white", 1500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ImportPGN(copyID, pgn); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := repertoireMoves(t, m, copyID), repertoireMoves(t, m, repID); !slices.Equal(got, want) {
+		t.Errorf("re-imported moves = %q, want %q", got, want)
+	}
+}
+
+func TestPGNAnnotationsRoundTrip(t *testing.T) {
+	m, repID := newTestManager(t)
+	_, err := m.ImportPGN(repID, `{Open games [%csl Gd4]}
+1. e4! $14 {Main move [%cal Ge2e4,Rd7d5]} e5 ({Sharper} 1... c5 $2 2. Nf3 d6 3. d4)
+2. Nf3 Nc6 (2... d6 3. d4 Nc6 {Philidor}) 3. d4 (3. Bb5 a6 $5) d6 *`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		line []string
+		move string // empty for the position annotation
+		want Annotation
+	}{
+		{want: Annotation{Comment: "Open games", NAGs: []int{}, Arrows: []string{}, Squares: []string{"Gd4"}}},
+		{move: "e4", want: Annotation{NAGs: []int{1}, Arrows: []string{}, Squares: []string{}}},
+		{line: []string{"e4"}, want: Annotation{Comment: "Main move", NAGs: []int{14}, Arrows: []string{"Ge2e4", "Rd7d5"}, Squares: []string{}}},
+		{line: []string{"e4"}, move: "c5", want: Annotation{Comment: "Sharper", NAGs: []int{2}, Arrows: []string{}, Squares: []string{}}},
+		{line: []string{"e4", "e5", "Nf3", "Nc6", "Bb5"}, move: "a6", want: Annotation{NAGs: []int{5}, Arrows: []string{}, Squares: []string{}}},
+		{line: []string{"e4", "e5", "Nf3", "Nc6", "d4", "d6"}, want: Annotation{Comment: "Philidor", NAGs: []int{}, Arrows: []string{}, Squares: []string{}}},
+	}
+	for _, tt := range tests {
+		m.SetCurrentFEN(StartFEN)
+		for _, san := range tt.line {
+			if err := m.PlayMoveSAN(san); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var got Annotation
+		if tt.move == "" {
+			got, err = m.GetPositionAnnotation()
+		} else {
+			got, err = m.GetMoveAnnotation(tt.move)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q %s: annotation = %+v, want %+v", tt.line, tt.move, got, tt.want)
+		}
+	}
+
+	// The transposition to the Philidor is exported as a comment, not an annotation.
+	pgn, err := m.ExportPGN(repID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(pgn, "{"+transposeComment) {
+		t.Errorf("no transposition comment in the export:\n%s", pgn)
+	}
+	copyID, err := m.Create("Copy", "white", 1500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ImportPGN(copyID, pgn); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	want, err := loadAnnotations(ctx, m.db, repID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := loadAnnotations(ctx, m.db, copyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("re-imported annotations = %+v, want %+v\n%s", got, want, pgn)
+	}
+	if got, want := repertoireMoves(t, m, copyID), repertoireMoves(t, m, repID); !slices.Equal(got, want) {
+		t.Errorf("re-imported moves = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,41 @@
+package backend
+
+import (
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// positionKey normalises a FEN to the key positions are stored under: piece placement,
+// side to move, castling rights and the en passant square only when an en passant
+// capture is legal, with the move counters reset to "0 1". Move orders that transpose
+// to the same position get the same key, and the key is still a valid FEN. FENs that
+// cannot be parsed are returned unchanged.
+func positionKey(fen string) string {
+	fields := strings.Fields(fen)
+	if len(fields) < 4 {
+		return fen
+	}
+	key := []string{fields[0], fields[1], fields[2], fields[3], "0", "1"}
+	if key[3] != "-" && !enPassantPossible(strings.Join(key, " ")) {
+		key[3] = "-"
+	}
+	return strings.Join(key, " ")
+}
+
+// startKey is the key of the standard initial position.
+var startKey = positionKey(StartFEN)
+
+// enPassantPossible reports whether the side to move can capture en passant.
+func enPassantPossible(fen string) bool {
+	pos, err := positionFromFEN(fen)
+	if err != nil {
+		return false
+	}
+	for _, mv := range pos.ValidMoves() {
+		if mv.HasTag(chess.EnPassant) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package backend
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// UpdateReach computes, for every position of a repertoire, the probability of reaching
+// it from the start position and stores it with the node. A line's probability is the
+// product of the explorer frequencies of the opponent's moves along it, our own moves
+// counting as certain; a position reached by several lines takes its most likely one.
+// Recorded roots, such as the [FEN] root of an imported game, count as reached for
+// certain, as the start position does. Positions reached from no root, or only through
+// deprecated moves, get zero. It returns how many positions were updated.
+func (m *RepertoireManager) UpdateReach(repID int64) (int, error) {
+	ctx, done := m.beginOperation()
+	defer done()
+
+	color, err := m.repertoireColor(ctx, repID)
+	if err != nil {
+		return 0, canceledError(err)
+	}
+	q, err := m.repertoireExplorerQuery(ctx, repID)
+	if err != nil {
+		return 0, canceledError(err)
+	}
+	g, err := loadGraph(ctx, m.db, repID)
+	if err != nil {
+		return 0, canceledError(err)
+	}
+	g.withoutDeprecated()
+	w, err := m.computeReach(ctx, g, color, q)
+	if err != nil {
+		return 0, canceledError(err)
+	}
+
+	err = m.inTx(ctx, func(tx *sql.Tx) error {
+		for fen := range g.nodes {
+			if err := checkContext(ctx); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx,
+				`UPDATE nodes SET reach = ? WHERE rep_id = ? AND fen = ?`, w.prob[fen], repID, fen)
+			if err != nil {
+				return fmt.Errorf("failed to save reach: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(g.nodes), nil
+}
+
+// computeReach walks the stored moves of g from each of its roots, weighting the
+// opponent's moves by their explorer frequencies.
+func (m *RepertoireManager) computeReach(ctx context.Context, g *repGraph, color string, q ExplorerQuery) (*reachWalk, error) {
+	w := newGraphWalk(g)
+	for w.more() {
+		if err := checkContext(ctx); err != nil {
+			return nil, err
+		}
+		fen, p := w.pop()
+		theirs := sideToMove(fen) != color
+		freq := map[string]float64{}
+		if theirs && p > 0 && len(g.children[fen]) > 0 {
+			replies, err := m.moveFrequencies(ctx, w.display[fen], q)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range replies {
+				freq[r.SAN] = r.Frequency
+			}
+		}
+		for _, e := range g.children[fen] {
+			reach := p
+			if theirs {
+				reach *= freq[e.MoveSAN]
+			}
+			w.reach(fen, e.ChildFEN, g.displayFEN(e.ChildFEN), e.MoveSAN, reach)
+		}
+	}
+	return w, nil
+}
+
+// ReachNode is a position in the reach tree of a repertoire.
+type ReachNode struct {
+	FEN           string       `json:"fen"`
+	Move          string       `json:"move"`          // move leading here, empty at the root
+	Kind          string       `json:"kind"`          // kind of that move
+	Reach         float64      `json:"reach"`         // probability of reaching the position from the start, 0 to 1
+	Transposition bool         `json:"transposition"` // expanded elsewhere in the tree, so without children here
+	Children      []*ReachNode `json:"children"`      // most likely first
+}
+
+// GetReachTree returns a repertoire as a tree from the start position, annotated with
+// the reach stored by the last UpdateReach. Lines reached less often than minReach are
+// left out. A position reached by several lines is expanded under its most likely
+// parent and marked as a transposition elsewhere. Deprecated moves are not shown. Recorded
+// roots, such as the [FEN] root of an imported game, are listed under the start
+// position without a move.
+func (m *RepertoireManager) GetReachTree(repID int64, minReach float64) (*ReachNode, error) {
+	g, err := loadGraph(m.baseContext(), m.db, repID)
+	if err != nil {
+		return nil, err
+	}
+	g.withoutDeprecated()
+
+	root := &ReachNode{FEN: g.displayFEN(startKey), Reach: g.reach[startKey], Children: []*ReachNode{}}
+	nodes := map[string]*ReachNode{startKey: root}
+	queue := []string{startKey}
+	for _, fen := range g.roots()[1:] {
+		if g.reach[fen] < minReach {
+			continue
+		}
+		other := &ReachNode{FEN: g.displayFEN(fen), Reach: g.reach[fen], Children: []*ReachNode{}}
+		nodes[fen] = other
+		queue = append(queue, fen)
+		root.Children = append(root.Children, other)
+	}
+	for len(queue) > 0 {
+		// Expand the most likely position first so it claims the children it shares.
+		best := 0
+		for i, fen := range queue {
+			if g.reach[fen] > g.reach[queue[best]] {
+				best = i
+			}
+		}
+		fen := queue[best]
+		queue = append(queue[:best], queue[best+1:]...)
+
+		parent := nodes[fen]
+		for _, e := range g.children[fen] {
+			if g.reach[e.ChildFEN] < minReach {
+				continue
+			}
+			child := &ReachNode{
+				FEN:      g.displayFEN(e.ChildFEN),
+				Move:     e.MoveSAN,
+				Kind:     e.Kind,
+				Reach:    g.reach[e.ChildFEN],
+				Children: []*ReachNode{},
+			}
+			if _, ok := nodes[e.ChildFEN]; ok {
+				child.Transposition = true
+			} else {
+				nodes[e.ChildFEN] = child
+				queue = append(queue, e.ChildFEN)
+			}
+			parent.Children = append(parent.Children, child)
+		}
+		sort.SliceStable(parent.Children, func(i, j int) bool {
+			return parent.Children[i].Reach > parent.Children[j].Reach
+		})
+	}
+	return root, nil
+}
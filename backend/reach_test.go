@@ -0,0 +1,87 @@
+package backend
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// explorerMoves returns a canned explorer response listing moves with their game
+// counts, all won by White, most played first as the explorer does.
+func explorerMoves(moves map[string]int) ExplorerResponse {
+	var r ExplorerResponse
+	for san, games := range moves {
+		r.Moves = append(r.Moves, Move{SAN: san, White: games})
+		r.White += games
+	}
+	slices.SortFunc(r.Moves, func(a, b Move) int {
+		if a.White != b.White {
+			return b.White - a.White
+		}
+		return strings.Compare(a.SAN, b.SAN)
+	})
+	return r
+}
+
+func TestUpdateReachMultipliesOpponentFrequencies(t *testing.T) {
+	fe := NewFakeExplorer()
+	m, repID := newTestManager(t, WithExplorer(fe))
+	addLine(t, m, "e4", "e5", "Nf3", "Nc6", "Bb5")
+	addLine(t, m, "e4", "c5", "Nf3")
+	e4 := playLine(t, "e4", "e5", "Nf3", "Nc6", "Bb5")
+	c5 := playLine(t, "e4", "c5", "Nf3")
+	fe.Responses[e4[0]] = explorerMoves(map[string]int{"e5": 60, "c5": 40})
+	fe.Responses[e4[2]] = explorerMoves(map[string]int{"Nc6": 50, "d6": 30, "Nf6": 20})
+
+	// A position annotated while browsing hangs off no root and must not count as one.
+	stray := playLine(t, "d4", "d5")[1]
+	m.SetCurrentFEN(stray)
+	if err := m.SetPositionAnnotation(Annotation{Comment: "Queen's Gambit?"}); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := m.UpdateReach(repID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 9 {
+		t.Errorf("updated %d positions, want 9", n)
+	}
+	want := map[string]float64{
+		StartFEN: 1,
+		e4[0]:    1,
+		e4[1]:    0.6,
+		e4[2]:    0.6,
+		e4[3]:    0.3, // 0.6 for 1...e5 times 0.5 for 2...Nc6
+		e4[4]:    0.3,
+		c5[1]:    0.4,
+		c5[2]:    0.4,
+		stray:    0,
+	}
+	for fen, p := range want {
+		var got float64
+		err := m.db.QueryRow(`SELECT reach FROM nodes WHERE rep_id = ? AND fen = ?`, repID, positionKey(fen)).Scan(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(got-p) > 1e-9 {
+			t.Errorf("reach of %s = %v, want %v", fen, got, p)
+		}
+	}
+
+	tree, err := m.GetReachTree(repID, 0.35)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree.Children) != 1 || tree.Children[0].Move != "e4" {
+		t.Fatalf("reach tree children = %+v, want only 1.e4", tree.Children)
+	}
+	replies := tree.Children[0].Children
+	if len(replies) != 2 || replies[0].Move != "e5" || replies[1].Move != "c5" {
+		t.Errorf("replies to 1.e4 = %+v, want e5 then c5", replies)
+	}
+	if len(replies) > 0 && len(replies[0].Children) != 1 {
+		t.Errorf("below 1...e5 = %+v, want 2.Nf3 only, 2...Nc6 being below 0.35", replies[0].Children)
+	}
+}
@@ -0,0 +1,228 @@
+package backend
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// sqlTimeLayout matches SQLite's DATETIME() and CURRENT_TIMESTAMP, which are UTC.
+const sqlTimeLayout = "2006-01-02 15:04:05"
+
+func formatSQLTime(t time.Time) string {
+	return t.UTC().Format(sqlTimeLayout)
+}
+
+func parseSQLTime(s sql.NullString) time.Time {
+	if !s.Valid {
+		return time.Time{}
+	}
+	t, err := time.Parse(sqlTimeLayout, s.String)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// nowSQL is the manager's clock formatted for comparison with stored dates.
+func (m *RepertoireManager) nowSQL() string {
+	return formatSQLTime(m.now())
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	queryer
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func loadCard(ctx context.Context, q execer, repID int64, fen string) (CardState, error) {
+	var (
+		c               CardState
+		due, lastReview sql.NullString
+	)
+	err := q.QueryRowContext(ctx,
+		`SELECT sr_index, ease, interval, stability, difficulty, reps, lapses, due, last_review
+		 FROM nodes WHERE rep_id = ? AND fen = ?`, repID, fen).Scan(
+		&c.Box, &c.Ease, &c.Interval, &c.Stability, &c.Difficulty, &c.Reps, &c.Lapses, &due, &lastReview)
+	if err != nil {
+		return CardState{}, err
+	}
+	c.Due = parseSQLTime(due)
+	c.LastReview = parseSQLTime(lastReview)
+	return c, nil
+}
+
+func saveCard(ctx context.Context, q execer, repID int64, fen string, c CardState) error {
+	_, err := q.ExecContext(ctx,
+		`UPDATE nodes SET sr_index = ?, ease = ?, interval = ?, stability = ?, difficulty = ?,
+		 reps = ?, lapses = ?, due = ?, last_review = ?
+		 WHERE rep_id = ? AND fen = ?`,
+		c.Box, c.Ease, c.Interval, c.Stability, c.Difficulty, c.Reps, c.Lapses,
+		formatSQLTime(c.Due), formatSQLTime(c.LastReview), repID, fen)
+	return err
+}
+
+// repertoireScheduler returns the scheduler configured for a repertoire.
+func (m *RepertoireManager) repertoireScheduler(ctx context.Context, q execer, repID int64) (Scheduler, error) {
+	var name string
+	err := q.QueryRowContext(ctx, `SELECT scheduler FROM repertoire WHERE id = ?`, repID).Scan(&name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scheduler: %w", err)
+	}
+	return NewScheduler(name)
+}
+
+// gradePosition records a review of fen in repertoire repID, reschedules it and
+// logs the answer. move is the move attempted, empty for a self-assessed review.
+func (m *RepertoireManager) gradePosition(ctx context.Context, repID int64, fen, move string, g Grade) (CardState, error) {
+	if !g.valid() {
+		return CardState{}, fmt.Errorf("invalid grade %d", g)
+	}
+	now := m.now()
+	var c CardState
+	err := m.inTx(ctx, func(tx *sql.Tx) error {
+		s, err := m.repertoireScheduler(ctx, tx, repID)
+		if err != nil {
+			return err
+		}
+		c, err = loadCard(ctx, tx, repID, fen)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("position is not in the repertoire")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load review state: %w", err)
+		}
+		before := c
+		c = s.Schedule(c, g, now)
+		if err := saveCard(ctx, tx, repID, fen, c); err != nil {
+			return fmt.Errorf("failed to save review state: %w", err)
+		}
+		return logReview(ctx, tx, ReviewEntry{
+			RepID:      repID,
+			FEN:        fen,
+			Move:       move,
+			Correct:    g != GradeAgain,
+			Grade:      g,
+			ResponseMs: m.responseTime(fen, now).Milliseconds(),
+			Before:     before,
+			After:      c,
+			ReviewedAt: now,
+		})
+	})
+	if err != nil {
+		return CardState{}, err
+	}
+	return c, nil
+}
+
+// GradeResult is the outcome of answering a position.
+type GradeResult struct {
+	FEN       string    `json:"fen"`
+	Move      string    `json:"move"` // the move given
+	Correct   bool      `json:"correct"`
+	Expected  []string  `json:"expected"`  // the repertoire's moves in the position, in SAN, primary first
+	Primary   string    `json:"primary"`   // the move the repertoire teaches
+	Alternate bool      `json:"alternate"` // an alternate move was given, accepted with a lower grade
+	Grade     Grade     `json:"grade"`
+	Box       int       `json:"box"`
+	Interval  float64   `json:"interval"` // days until the next review
+	Due       time.Time `json:"due"`
+}
+
+// gradeMove checks moveSAN against the repertoire's moves in fen, then grades and logs
+// the answer: the primary move is graded good, an alternate hard and anything else again.
+// When the answer is accepted it returns the position the primary move leads to, so
+// training always continues along the primary move. Answers are compared in canonical
+// SAN, so "Nf3+" or "g1f3" count as "Nf3".
+func (m *RepertoireManager) gradeMove(ctx context.Context, repID int64, fen, moveSAN string) (GradeResult, string, error) {
+	if san, _, err := ApplyMove(fen, moveSAN); err == nil {
+		moveSAN = san
+	}
+	rows, err := m.db.QueryContext(ctx,
+		`SELECT move, child_fen, kind FROM edges
+		 WHERE rep_id = ? AND parent_fen = ? AND kind IN (?, ?)
+		 ORDER BY kind = ? DESC, rowid`,
+		repID, fen, EdgePrimary, EdgeAlternate, EdgePrimary)
+	if err != nil {
+		return GradeResult{}, "", fmt.Errorf("failed to validate move: %w", err)
+	}
+	defer rows.Close()
+
+	res := GradeResult{FEN: fen, Move: moveSAN, Expected: []string{}, Grade: GradeAgain}
+	var primaryFEN string
+	for rows.Next() {
+		var move, child, kind string
+		if err := rows.Scan(&move, &child, &kind); err != nil {
+			return GradeResult{}, "", err
+		}
+		if len(res.Expected) == 0 {
+			// Without a primary move the first alternate is taught in its place.
+			res.Primary = move
+			primaryFEN = child
+		}
+		res.Expected = append(res.Expected, move)
+		if move == moveSAN {
+			res.Correct = true
+			res.Alternate = move != res.Primary
+			res.Grade = GradeGood
+			if res.Alternate {
+				res.Grade = GradeHard
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return GradeResult{}, "", err
+	}
+	rows.Close()
+
+	c, err := m.gradePosition(ctx, repID, fen, moveSAN, res.Grade)
+	if err != nil {
+		return GradeResult{}, "", err
+	}
+	res.Box = c.Box
+	res.Interval = c.Interval
+	res.Due = c.Due
+	if !res.Correct {
+		return res, "", nil
+	}
+	return res, primaryFEN, nil
+}
+
+// GradeCurrentPosition records a self-assessed review of the current position
+// (1 again, 2 hard, 3 good, 4 easy) and returns its new schedule.
+func (m *RepertoireManager) GradeCurrentPosition(grade int) (CardState, error) {
+	repID, fen, err := m.currentPosition()
+	if err != nil {
+		return CardState{}, err
+	}
+	return m.gradePosition(m.baseContext(), repID, positionKey(fen), "", Grade(grade))
+}
+
+// GetScheduler returns the name of the scheduler a repertoire uses.
+func (m *RepertoireManager) GetScheduler(repID int64) (string, error) {
+	s, err := m.repertoireScheduler(m.baseContext(), m.db, repID)
+	if err != nil {
+		return "", err
+	}
+	return s.Name(), nil
+}
+
+// SetScheduler switches a repertoire to the "leitner", "sm2" or "fsrs" scheduler.
+// Review state is kept, so positions carry their due dates over to the new scheduler.
+func (m *RepertoireManager) SetScheduler(repID int64, name string) error {
+	s, err := NewScheduler(name)
+	if err != nil {
+		return err
+	}
+	res, err := m.db.ExecContext(m.baseContext(),
+		`UPDATE repertoire SET scheduler = ? WHERE id = ?`, s.Name(), repID)
+	if err != nil {
+		return fmt.Errorf("failed to save scheduler: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("repertoire %d not found", repID)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package backend
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ReviewEntry is one recorded training answer.
+type ReviewEntry struct {
+	ID         int64     `json:"id"`
+	RepID      int64     `json:"repId"`
+	FEN        string    `json:"fen"`
+	Move       string    `json:"move"` // move attempted, empty for self-assessed reviews
+	Correct    bool      `json:"correct"`
+	Grade      Grade     `json:"grade"`
+	ResponseMs int64     `json:"responseMs"` // time from reaching the position to answering
+	Before     CardState `json:"before"`
+	After      CardState `json:"after"`
+	ReviewedAt time.Time `json:"reviewedAt"`
+}
+
+// PositionHistory summarises the answers given in one position.
+type PositionHistory struct {
+	FEN           string    `json:"fen"`
+	Reviews       int       `json:"reviews"`
+	Failures      int       `json:"failures"`
+	FailureRate   float64   `json:"failureRate"` // 0 to 1
+	AvgResponseMs int64     `json:"avgResponseMs"`
+	LastReviewed  time.Time `json:"lastReviewed"`
+	LastFailed    time.Time `json:"lastFailed"` // zero if never failed
+}
+
+// responseTime is how long the user took to answer in the position keyed fen, or zero if unknown.
+func (m *RepertoireManager) responseTime(fen string, now time.Time) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if positionKey(m.currentFEN) != fen || m.shownAt.IsZero() || now.Before(m.shownAt) {
+		return 0
+	}
+	return now.Sub(m.shownAt)
+}
+
+func logReview(ctx context.Context, tx *sql.Tx, e ReviewEntry) error {
+	before, err := json.Marshal(e.Before)
+	if err != nil {
+		return err
+	}
+	after, err := json.Marshal(e.After)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO review_log (rep_id, fen, move, correct, grade, response_ms, before, after, reviewed_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.RepID, e.FEN, e.Move, e.Correct, e.Grade, e.ResponseMs, string(before), string(after),
+		formatSQLTime(e.ReviewedAt))
+	if err != nil {
+		return fmt.Errorf("failed to log review: %w", err)
+	}
+	return nil
+}
+
+// GetPositionHistory returns the most recent answers given in fen in the selected
+// repertoire, newest first. A limit of zero or less returns all of them.
+func (m *RepertoireManager) GetPositionHistory(fen string, limit int) ([]ReviewEntry, error) {
+	repID, err := m.selected()
+	if err != nil {
+		return nil, err
+	}
+	return m.queryReviews(m.baseContext(),
+		`WHERE rep_id = ? AND fen = ?`, limit, repID, positionKey(fen))
+}
+
+// GetRepertoireHistory returns the most recent answers given in a repertoire, newest first.
+// A limit of zero or less returns all of them.
+func (m *RepertoireManager) GetRepertoireHistory(repID int64, limit int) ([]ReviewEntry, error) {
+	return m.queryReviews(m.baseContext(), `WHERE rep_id = ?`, limit, repID)
+}
+
+func (m *RepertoireManager) queryReviews(ctx context.Context, where string, limit int, args ...any) ([]ReviewEntry, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := m.db.QueryContext(ctx,
+		`SELECT id, rep_id, fen, move, correct, grade, response_ms, before, after, reviewed_at
+		 FROM review_log `+where+` ORDER BY reviewed_at DESC, id DESC LIMIT ?`,
+		append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch review history: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]ReviewEntry, 0)
+	for rows.Next() {
+		var (
+			e                    ReviewEntry
+			before, after, stamp string
+		)
+		err := rows.Scan(&e.ID, &e.RepID, &e.FEN, &e.Move, &e.Correct, &e.Grade, &e.ResponseMs,
+			&before, &after, &stamp)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(before), &e.Before); err != nil {
+			return nil, fmt.Errorf("invalid review state: %w", err)
+		}
+		if err := json.Unmarshal([]byte(after), &e.After); err != nil {
+			return nil, fmt.Errorf("invalid review state: %w", err)
+		}
+		e.ReviewedAt = parseSQLTime(sql.NullString{String: stamp, Valid: true})
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// GetFailingPositions lists the positions of a repertoire that have been answered wrongly,
+// highest failure rate first, so lines that keep going wrong can be drilled.
+func (m *RepertoireManager) GetFailingPositions(repID int64, limit int) ([]PositionHistory, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := m.db.QueryContext(m.baseContext(),
+		`SELECT fen, COUNT(*), SUM(1 - correct), CAST(AVG(response_ms) AS INTEGER),
+		        MAX(reviewed_at), MAX(CASE WHEN correct = 0 THEN reviewed_at END)
+		 FROM review_log WHERE rep_id = ?
+		 GROUP BY fen HAVING SUM(1 - correct) > 0
+		 ORDER BY SUM(1 - correct) * 1.0 / COUNT(*) DESC, SUM(1 - correct) DESC, fen
+		 LIMIT ?`, repID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch failing positions: %w", err)
+	}
+	defer rows.Close()
+
+	out := make([]PositionHistory, 0)
+	for rows.Next() {
+		var (
+			h                    PositionHistory
+			lastReview, lastFail sql.NullString
+		)
+		err := rows.Scan(&h.FEN, &h.Reviews, &h.Failures, &h.AvgResponseMs, &lastReview, &lastFail)
+		if err != nil {
+			return nil, err
+		}
+		h.FailureRate = float64(h.Failures) / float64(h.Reviews)
+		h.LastReviewed = parseSQLTime(lastReview)
+		h.LastFailed = parseSQLTime(lastFail)
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
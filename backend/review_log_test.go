@@ -0,0 +1,72 @@
+package backend
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReviewLog(t *testing.T) {
+	now := testNow
+	m, repID := newTestManager(t, WithClock(func() time.Time { return now }))
+	addLine(t, m, "e4", "e5", "Nf3")
+	afterE5 := playLine(t, "e4", "e5")[1]
+
+	m.SetCurrentFEN(StartFEN)
+	now = now.Add(3 * time.Second)
+	if _, err := m.TestCurrentPosition("d4"); err != nil {
+		t.Fatal(err)
+	}
+	// Response times run from reaching the position, not from the last answer.
+	now = now.Add(2 * time.Second)
+	if _, err := m.TestCurrentPosition("e4"); err != nil {
+		t.Fatal(err)
+	}
+	m.SetCurrentFEN(afterE5)
+	now = now.Add(time.Second)
+	if _, err := m.TestCurrentPosition("Nf3"); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := m.GetPositionHistory(StartFEN, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ReviewEntry{
+		{RepID: repID, FEN: startKey, Move: "e4", Correct: true, Grade: GradeGood, ResponseMs: 5000,
+			ReviewedAt: testNow.Add(5 * time.Second)},
+		{RepID: repID, FEN: startKey, Move: "d4", Grade: GradeAgain, ResponseMs: 3000,
+			ReviewedAt: testNow.Add(3 * time.Second)},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("got %d answers in the start position, want %d: %+v", len(history), len(want), history)
+	}
+	for i, e := range history {
+		w := want[i]
+		if e.RepID != w.RepID || e.FEN != w.FEN || e.Move != w.Move || e.Correct != w.Correct ||
+			e.Grade != w.Grade || e.ResponseMs != w.ResponseMs || !e.ReviewedAt.Equal(w.ReviewedAt) {
+			t.Errorf("answer %d = %+v, want %+v", i, e, w)
+		}
+	}
+	// Each entry records the schedule it changed.
+	if history[0].Before != history[1].After {
+		t.Errorf("second answer started from %+v, want the %+v left by the first", history[0].Before, history[1].After)
+	}
+	if !history[0].After.Due.After(now) {
+		t.Errorf("due %s after a correct answer, want a later review", history[0].After.Due)
+	}
+
+	all, err := m.GetRepertoireHistory(repID, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].Move != "Nf3" || all[0].FEN != positionKey(afterE5) || all[1].Move != "e4" {
+		t.Errorf("latest answers = %+v, want Nf3 then e4", all)
+	}
+	other, err := m.Create("Other", "black", 1500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := m.GetRepertoireHistory(other, 0); err != nil || len(entries) != 0 {
+		t.Errorf("history of an unreviewed repertoire = %+v, %v; want none", entries, err)
+	}
+}
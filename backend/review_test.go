@@ -0,0 +1,57 @@
+package backend
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTestCurrentPosition(t *testing.T) {
+	m, _ := newTestManager(t, WithClock(func() time.Time { return testNow }))
+	addLine(t, m, "e4", "e5")
+	addLine(t, m, "d4")
+	addLine(t, m, "c4")
+	m.SetCurrentFEN(StartFEN)
+	if err := m.SetEdgeKind("c4", EdgeDeprecated); err != nil {
+		t.Fatal(err)
+	}
+	e4 := playLine(t, "e4")[0]
+
+	tests := []struct {
+		move    string
+		want    GradeResult
+		wantFEN string // position afterwards
+	}{
+		{"e4", GradeResult{Move: "e4", Correct: true, Grade: GradeGood}, e4},
+		// Alternates are accepted with a lower grade, and training goes on along the primary move.
+		{"d2d4", GradeResult{Move: "d4", Correct: true, Alternate: true, Grade: GradeHard}, e4},
+		{"Nf3", GradeResult{Move: "Nf3", Grade: GradeAgain}, StartFEN},
+		// Deprecated moves are no longer taught.
+		{"c4", GradeResult{Move: "c4", Grade: GradeAgain}, StartFEN},
+	}
+	for _, tt := range tests {
+		m.SetCurrentFEN(StartFEN)
+		res, err := m.TestCurrentPosition(tt.move)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.move, err)
+		}
+		if res.FEN != startKey || res.Move != tt.want.Move || res.Correct != tt.want.Correct ||
+			res.Alternate != tt.want.Alternate || res.Grade != tt.want.Grade {
+			t.Errorf("%s: result = %+v, want %+v", tt.move, res, tt.want)
+		}
+		if res.Primary != "e4" || !slices.Equal(res.Expected, []string{"e4", "d4"}) {
+			t.Errorf("%s: primary %q of %q, want e4 of [e4 d4]", tt.move, res.Primary, res.Expected)
+		}
+		if res.Correct && !res.Due.After(testNow) {
+			t.Errorf("%s: due %s, want a later review", tt.move, res.Due)
+		}
+		if fen := m.GetCurrentFEN(); fen != tt.wantFEN {
+			t.Errorf("%s: position afterwards %q, want %q", tt.move, fen, tt.wantFEN)
+		}
+	}
+
+	m.SetCurrentFEN(e4)
+	if _, err := m.TestCurrentPosition("e5"); err == nil {
+		t.Error("an opponent's move was graded")
+	}
+}
@@ -0,0 +1,230 @@
+package backend
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Scheduler names stored in repertoire.scheduler.
+const (
+	SchedulerLeitner = "leitner"
+	SchedulerSM2     = "sm2"
+	SchedulerFSRS    = "fsrs"
+)
+
+// Grade is how well a position was recalled, on the usual four-button scale.
+type Grade int
+
+const (
+	GradeAgain Grade = iota + 1 // wrong move
+	GradeHard
+	GradeGood
+	GradeEasy
+)
+
+func (g Grade) valid() bool { return g >= GradeAgain && g <= GradeEasy }
+
+// CardState is the review state kept for each position.
+// Each scheduler uses the fields it needs and leaves the others alone.
+type CardState struct {
+	Box        int       `json:"box"`        // Leitner box (sr_index)
+	Ease       float64   `json:"ease"`       // SM-2 ease factor
+	Interval   float64   `json:"interval"`   // days until the next review
+	Stability  float64   `json:"stability"`  // FSRS stability, in days
+	Difficulty float64   `json:"difficulty"` // FSRS difficulty, 1 to 10
+	Reps       int       `json:"reps"`       // successful reviews in a row
+	Lapses     int       `json:"lapses"`
+	Due        time.Time `json:"due"`
+	LastReview time.Time `json:"lastReview"` // zero if never reviewed
+}
+
+// Scheduler decides when a position should be reviewed next.
+// Schedule must be deterministic: the same state, grade and time give the same result.
+type Scheduler interface {
+	Name() string
+	Schedule(c CardState, g Grade, now time.Time) CardState
+}
+
+// NewScheduler returns the scheduler with the given name; an empty name means Leitner.
+func NewScheduler(name string) (Scheduler, error) {
+	switch name {
+	case "", SchedulerLeitner:
+		return Leitner{}, nil
+	case SchedulerSM2:
+		return SM2{}, nil
+	case SchedulerFSRS:
+		return NewFSRS(), nil
+	}
+	return nil, fmt.Errorf("unknown scheduler %q", name)
+}
+
+// days converts a fractional number of days to a duration.
+func days(d float64) time.Duration {
+	return time.Duration(d * float64(24*time.Hour))
+}
+
+// Leitner moves positions between boxes with fixed intervals: up a box when
+// recalled, down one when missed.
+type Leitner struct{}
+
+// leitnerIntervals is the wait, in days, after a correct answer from each box.
+var leitnerIntervals = []float64{1, 3, 7, 21}
+
+func (Leitner) Name() string { return SchedulerLeitner }
+
+func (Leitner) Schedule(c CardState, g Grade, now time.Time) CardState {
+	top := len(leitnerIntervals) - 1
+	box := min(c.Box, top)
+	switch g {
+	case GradeAgain:
+		c.Box = max(c.Box-1, 0)
+		c.Interval = 1
+		c.Reps = 0
+		c.Lapses++
+	case GradeHard:
+		c.Interval = leitnerIntervals[box]
+		c.Reps++
+	case GradeGood:
+		c.Interval = leitnerIntervals[box]
+		c.Box = min(c.Box+1, top)
+		c.Reps++
+	case GradeEasy:
+		c.Interval = leitnerIntervals[min(box+1, top)]
+		c.Box = min(c.Box+2, top)
+		c.Reps++
+	}
+	c.LastReview = now
+	c.Due = now.Add(days(c.Interval))
+	return c
+}
+
+// SM2 is the SuperMemo 2 algorithm: intervals grow by a per-position ease factor
+// that drops whenever a recall is hard or fails.
+type SM2 struct{}
+
+const (
+	sm2InitialEase = 2.5
+	sm2MinEase     = 1.3
+)
+
+func (SM2) Name() string { return SchedulerSM2 }
+
+func (SM2) Schedule(c CardState, g Grade, now time.Time) CardState {
+	if c.Ease == 0 {
+		c.Ease = sm2InitialEase
+	}
+	// SM-2 grades answers from 0 to 5; 3 and up count as recalled.
+	q := float64(g) + 1
+	if g == GradeAgain {
+		c.Reps = 0
+		c.Lapses++
+		c.Interval = 1
+	} else {
+		c.Reps++
+		switch c.Reps {
+		case 1:
+			c.Interval = 1
+		case 2:
+			c.Interval = 6
+		default:
+			c.Interval = math.Round(c.Interval * c.Ease)
+		}
+	}
+	c.Ease = math.Max(sm2MinEase, c.Ease+0.1-(5-q)*(0.08+(5-q)*0.02))
+	c.Box = c.Reps
+	c.LastReview = now
+	c.Due = now.Add(days(c.Interval))
+	return c
+}
+
+// FSRS is the Free Spaced Repetition Scheduler (version 4.5). It models each
+// position's memory stability and difficulty and schedules the next review for
+// when the chance of recall falls to RequestRetention.
+type FSRS struct {
+	Weights          [17]float64
+	RequestRetention float64
+	MaxInterval      float64 // days
+}
+
+// fsrsDefaultWeights are the published FSRS-4.5 default parameters.
+var fsrsDefaultWeights = [17]float64{
+	0.4872, 1.4003, 3.7145, 13.8206, 5.1618, 1.2298, 0.8975, 0.031,
+	1.6474, 0.1367, 1.0461, 2.1072, 0.0793, 0.3246, 1.587, 0.2272, 2.8755,
+}
+
+const (
+	fsrsDecay  = -0.5
+	fsrsFactor = 19.0 / 81.0 // makes retrievability 0.9 after one stability interval
+)
+
+// NewFSRS returns an FSRS scheduler with the default parameters and 90% retention.
+func NewFSRS() FSRS {
+	return FSRS{Weights: fsrsDefaultWeights, RequestRetention: 0.9, MaxInterval: 36500}
+}
+
+func (FSRS) Name() string { return SchedulerFSRS }
+
+func (f FSRS) Schedule(c CardState, g Grade, now time.Time) CardState {
+	w := f.Weights
+	// Positions reviewed only by another scheduler have no memory state yet, so their
+	// next review is treated as the first one.
+	if c.Stability <= 0 || c.Difficulty <= 0 {
+		c.Stability = w[g-1]
+		c.Difficulty = f.initialDifficulty(g)
+	} else {
+		elapsed := 0.0
+		if !c.LastReview.IsZero() {
+			elapsed = math.Max(0, now.Sub(c.LastReview).Hours()/24)
+		}
+		r := f.retrievability(elapsed, c.Stability)
+		if g == GradeAgain {
+			c.Stability = w[11] * math.Pow(c.Difficulty, -w[12]) *
+				(math.Pow(c.Stability+1, w[13]) - 1) * math.Exp(w[14]*(1-r))
+		} else {
+			bonus := 1.0
+			if g == GradeHard {
+				bonus = w[15]
+			} else if g == GradeEasy {
+				bonus = w[16]
+			}
+			c.Stability *= 1 + math.Exp(w[8])*(11-c.Difficulty)*math.Pow(c.Stability, -w[9])*
+				(math.Exp(w[10]*(1-r))-1)*bonus
+		}
+		d := c.Difficulty - w[6]*(float64(g)-3)
+		c.Difficulty = clamp(w[7]*f.initialDifficulty(GradeGood)+(1-w[7])*d, 1, 10)
+	}
+	if g == GradeAgain {
+		c.Reps = 0
+		c.Lapses++
+	} else {
+		c.Reps++
+	}
+	c.Interval = f.interval(c.Stability)
+	c.Box = c.Reps
+	c.LastReview = now
+	c.Due = now.Add(days(c.Interval))
+	return c
+}
+
+func (f FSRS) initialDifficulty(g Grade) float64 {
+	return clamp(f.Weights[4]-(float64(g)-3)*f.Weights[5], 1, 10)
+}
+
+// retrievability is the probability of recall after elapsed days.
+func (FSRS) retrievability(elapsed, stability float64) float64 {
+	if stability <= 0 {
+		return 0
+	}
+	return math.Pow(1+fsrsFactor*elapsed/stability, fsrsDecay)
+}
+
+// interval is the number of whole days until recall drops to the requested retention.
+func (f FSRS) interval(stability float64) float64 {
+	i := stability / fsrsFactor * (math.Pow(f.RequestRetention, 1/fsrsDecay) - 1)
+	return clamp(math.Round(i), 1, f.MaxInterval)
+}
+
+func clamp(x, lo, hi float64) float64 {
+	return math.Min(math.Max(x, lo), hi)
+}
@@ -0,0 +1,213 @@
+package backend
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestLeitnerSchedule(t *testing.T) {
+	tests := []struct {
+		name     string
+		card     CardState
+		grade    Grade
+		box      int
+		interval float64
+		reps     int
+		lapses   int
+	}{
+		{"new card good", CardState{}, GradeGood, 1, 1, 1, 0},
+		{"new card easy", CardState{}, GradeEasy, 2, 3, 1, 0},
+		{"hard stays in box", CardState{Box: 2, Reps: 2}, GradeHard, 2, 7, 3, 0},
+		{"good from box 2", CardState{Box: 2, Reps: 2}, GradeGood, 3, 7, 3, 0},
+		{"top box stays", CardState{Box: 3, Reps: 4}, GradeGood, 3, 21, 5, 0},
+		{"again drops a box", CardState{Box: 2, Reps: 2}, GradeAgain, 1, 1, 0, 1},
+		{"again in box 0", CardState{}, GradeAgain, 0, 1, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Leitner{}.Schedule(tt.card, tt.grade, testNow)
+			if c.Box != tt.box || c.Interval != tt.interval || c.Reps != tt.reps || c.Lapses != tt.lapses {
+				t.Errorf("got box %d interval %v reps %d lapses %d, want box %d interval %v reps %d lapses %d",
+					c.Box, c.Interval, c.Reps, c.Lapses, tt.box, tt.interval, tt.reps, tt.lapses)
+			}
+			if want := testNow.Add(days(tt.interval)); !c.Due.Equal(want) {
+				t.Errorf("due %v, want %v", c.Due, want)
+			}
+			if !c.LastReview.Equal(testNow) {
+				t.Errorf("last review %v, want %v", c.LastReview, testNow)
+			}
+		})
+	}
+}
+
+func TestSM2Schedule(t *testing.T) {
+	tests := []struct {
+		name     string
+		card     CardState
+		grade    Grade
+		interval float64
+		ease     float64
+		reps     int
+	}{
+		{"first review", CardState{}, GradeGood, 1, 2.5, 1},
+		{"second review", CardState{Ease: 2.5, Reps: 1, Interval: 1}, GradeEasy, 6, 2.6, 2},
+		{"third review grows by ease", CardState{Ease: 2.5, Reps: 2, Interval: 6}, GradeGood, 15, 2.5, 3},
+		{"hard lowers ease", CardState{Ease: 2.5, Reps: 2, Interval: 6}, GradeHard, 15, 2.36, 3},
+		{"again resets", CardState{Ease: 2.5, Reps: 4, Interval: 40}, GradeAgain, 1, 2.18, 0},
+		{"ease floor", CardState{Ease: 1.3, Reps: 3, Interval: 10}, GradeAgain, 1, 1.3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := SM2{}.Schedule(tt.card, tt.grade, testNow)
+			if c.Interval != tt.interval || math.Abs(c.Ease-tt.ease) > 1e-9 || c.Reps != tt.reps {
+				t.Errorf("got interval %v ease %v reps %d, want interval %v ease %v reps %d",
+					c.Interval, c.Ease, c.Reps, tt.interval, tt.ease, tt.reps)
+			}
+			if want := testNow.Add(days(tt.interval)); !c.Due.Equal(want) {
+				t.Errorf("due %v, want %v", c.Due, want)
+			}
+		})
+	}
+}
+
+func TestFSRSFirstReview(t *testing.T) {
+	f := NewFSRS()
+	tests := []struct {
+		grade    Grade
+		interval float64
+	}{
+		{GradeAgain, 1},
+		{GradeHard, 1},
+		{GradeGood, 4},
+		{GradeEasy, 14},
+	}
+	for _, tt := range tests {
+		c := f.Schedule(CardState{}, tt.grade, testNow)
+		if c.Stability != fsrsDefaultWeights[tt.grade-1] {
+			t.Errorf("grade %d: stability %v, want %v", tt.grade, c.Stability, fsrsDefaultWeights[tt.grade-1])
+		}
+		if c.Difficulty != f.initialDifficulty(tt.grade) {
+			t.Errorf("grade %d: difficulty %v, want %v", tt.grade, c.Difficulty, f.initialDifficulty(tt.grade))
+		}
+		if c.Interval != tt.interval {
+			t.Errorf("grade %d: interval %v, want %v", tt.grade, c.Interval, tt.interval)
+		}
+	}
+}
+
+func TestFSRSLaterReviews(t *testing.T) {
+	f := NewFSRS()
+	first := f.Schedule(CardState{}, GradeGood, testNow)
+
+	good := f.Schedule(first, GradeGood, first.Due)
+	if good.Stability <= first.Stability || good.Interval <= first.Interval {
+		t.Errorf("good review: stability %v interval %v, want more than %v and %v",
+			good.Stability, good.Interval, first.Stability, first.Interval)
+	}
+	again := f.Schedule(first, GradeAgain, first.Due)
+	if again.Stability >= first.Stability || again.Lapses != 1 || again.Reps != 0 {
+		t.Errorf("failed review: stability %v lapses %d reps %d, want below %v, 1 lapse, 0 reps",
+			again.Stability, again.Lapses, again.Reps, first.Stability)
+	}
+	if again.Difficulty <= first.Difficulty {
+		t.Errorf("failed review: difficulty %v, want more than %v", again.Difficulty, first.Difficulty)
+	}
+
+	// The same state, grade and time must always give the same result.
+	if f.Schedule(first, GradeGood, first.Due) != good {
+		t.Error("Schedule is not deterministic")
+	}
+}
+
+// A card reviewed by Leitner or SM-2 has reps but no FSRS memory state.
+func TestFSRSAfterOtherSchedulers(t *testing.T) {
+	f := NewFSRS()
+	for _, s := range []Scheduler{Leitner{}, SM2{}} {
+		c := s.Schedule(CardState{}, GradeGood, testNow)
+		c = s.Schedule(c, GradeGood, c.Due)
+		for _, g := range []Grade{GradeAgain, GradeHard, GradeGood, GradeEasy} {
+			next := f.Schedule(c, g, c.Due)
+			if !finite(next.Stability) || !finite(next.Difficulty) || !finite(next.Interval) {
+				t.Fatalf("%s then FSRS grade %d: stability %v difficulty %v interval %v",
+					s.Name(), g, next.Stability, next.Difficulty, next.Interval)
+			}
+			if next.Stability != fsrsDefaultWeights[g-1] {
+				t.Errorf("%s then FSRS grade %d: stability %v, want first-review %v",
+					s.Name(), g, next.Stability, fsrsDefaultWeights[g-1])
+			}
+			if !next.Due.After(c.Due) {
+				t.Errorf("%s then FSRS grade %d: due %v, not after %v", s.Name(), g, next.Due, c.Due)
+			}
+		}
+	}
+}
+
+func finite(x float64) bool {
+	return !math.IsNaN(x) && !math.IsInf(x, 0)
+}
+
+func TestSetSchedulerKeepsReviewState(t *testing.T) {
+	db, err := Open("file:" + filepath.Join(t.TempDir(), "repertoire.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	now := testNow
+	m := NewRepertoireManager(db.SQL, WithExplorer(NewFakeExplorer()), WithClock(func() time.Time { return now }))
+	repID, err := m.Create("Main", "white", 1500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SelectRepertoire(repID)
+
+	tests := []struct {
+		scheduler string
+		grade     Grade
+		interval  float64
+	}{
+		{SchedulerLeitner, GradeGood, 1},
+		{SchedulerLeitner, GradeGood, 3},
+		{SchedulerSM2, GradeGood, 8}, // third success in a row: 3 days times the initial ease
+		{SchedulerFSRS, GradeGood, 4},
+		{SchedulerFSRS, GradeGood, 0}, // grows from the previous FSRS review
+		{SchedulerSM2, GradeAgain, 1},
+		{SchedulerFSRS, GradeAgain, 0},
+	}
+	var prev CardState
+	for i, tt := range tests {
+		if err := m.SetScheduler(repID, tt.scheduler); err != nil {
+			t.Fatal(err)
+		}
+		c, err := m.GradeCurrentPosition(int(tt.grade))
+		if err != nil {
+			t.Fatalf("review %d (%s): %v", i+1, tt.scheduler, err)
+		}
+		if !finite(c.Stability) || !finite(c.Difficulty) || !finite(c.Interval) {
+			t.Fatalf("review %d (%s): stability %v difficulty %v interval %v",
+				i+1, tt.scheduler, c.Stability, c.Difficulty, c.Interval)
+		}
+		if tt.interval > 0 && c.Interval != tt.interval {
+			t.Errorf("review %d (%s): interval %v, want %v", i+1, tt.scheduler, c.Interval, tt.interval)
+		}
+		if tt.interval == 0 && tt.grade != GradeAgain && c.Interval <= prev.Interval {
+			t.Errorf("review %d (%s): interval %v, want more than %v", i+1, tt.scheduler, c.Interval, prev.Interval)
+		}
+		if !c.Due.Equal(now.Add(days(c.Interval))) {
+			t.Errorf("review %d (%s): due %v, want %v", i+1, tt.scheduler, c.Due, now.Add(days(c.Interval)))
+		}
+
+		stored, err := loadCard(m.baseContext(), db.SQL, repID, startKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Stability != c.Stability || stored.Box != c.Box || !stored.Due.Equal(c.Due) {
+			t.Errorf("review %d (%s): stored %+v, want %+v", i+1, tt.scheduler, stored, c)
+		}
+		prev = c
+		now = c.Due
+	}
+}
@@ -1,7 +1,10 @@
 package backend
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -24,40 +27,117 @@ func Open(dsn string) (*DB, error) {
 
 func (d *DB) Close() error { return d.SQL.Close() }
 
+// SchemaVersion returns the migration version the database is at.
+func (d *DB) SchemaVersion() (int, error) {
+	return schemaVersion(d.SQL)
+}
+
+// migration is one ordered schema change. Up runs inside a transaction that also
+// records the new version, so a failed migration leaves the database untouched.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+func schemaVersion(db *sql.DB) (int, error) {
+	var v int
+	err := db.QueryRow(`PRAGMA user_version`).Scan(&v)
+	return v, err
+}
+
+// migrate brings the schema up to the latest version, backing up an existing
+// database file before the first pending migration is applied.
 func migrate(db *sql.DB) error {
-	_, err := db.Exec(`
-    CREATE TABLE IF NOT EXISTS repertoire (
-      id       INTEGER PRIMARY KEY AUTOINCREMENT,
-      name     TEXT NOT NULL UNIQUE,
-      color    TEXT NOT NULL CHECK (color IN ('white','black')),
-      elo      INTEGER NOT NULL DEFAULT 1200,
-      coverage REAL NOT NULL DEFAULT 0.0
-    );
-    CREATE TABLE IF NOT EXISTS nodes (
-      fen         TEXT NOT NULL,
-      rep_id      INTEGER NOT NULL,
-      sr_index    INTEGER NOT NULL DEFAULT 0,
-      due         INTEGER,
-      last_review INTEGER,
-      PRIMARY KEY (fen, rep_id),
-      FOREIGN KEY (rep_id) REFERENCES repertoire(id) ON DELETE CASCADE
-    );
-    CREATE TABLE IF NOT EXISTS stats (
-      fen        TEXT PRIMARY KEY,
-      games      INTEGER NOT NULL DEFAULT 0,
-      white_win  REAL NOT NULL DEFAULT 0.0,
-      black_win  REAL NOT NULL DEFAULT 0.0,
-      draw       REAL NOT NULL DEFAULT 0.0,
-      moves      TEXT NOT NULL DEFAULT '[]'
-    );
-    CREATE TABLE IF NOT EXISTS edges (
-      rep_id     INTEGER NOT NULL,
-      parent_fen TEXT NOT NULL,
-      child_fen  TEXT NOT NULL,
-      move       TEXT NOT NULL,
-      PRIMARY KEY (rep_id, parent_fen, child_fen),
-      FOREIGN KEY (rep_id) REFERENCES repertoire(id) ON DELETE CASCADE
-    );
-    `)
-	return err
+	for i, m := range migrations {
+		if m.version != i+1 {
+			return fmt.Errorf("migration %q has version %d, expected %d", m.name, m.version, i+1)
+		}
+	}
+	latest := len(migrations)
+
+	current, err := schemaVersion(db)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if current > latest {
+		return fmt.Errorf("database schema version %d is newer than this application supports (%d)", current, latest)
+	}
+	if current == latest {
+		return nil
+	}
+	if err := backupBeforeMigration(db, current); err != nil {
+		return err
+	}
+
+	for _, m := range migrations[current:] {
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, m.version)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// backupBeforeMigration copies a file database that already holds data next to
+// itself, named after the version it is migrated from. New and in-memory
+// databases are not backed up.
+func backupBeforeMigration(db *sql.DB, from int) error {
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(1) FROM sqlite_master WHERE type = 'table'`).Scan(&tables); err != nil {
+		return err
+	}
+	if tables == 0 {
+		return nil
+	}
+
+	var (
+		seq        int
+		name, path string
+	)
+	err := db.QueryRow(`SELECT seq, name, file FROM pragma_database_list WHERE name = 'main'`).Scan(&seq, &name, &path)
+	if err != nil {
+		return fmt.Errorf("failed to locate database file: %w", err)
+	}
+	if path == "" {
+		return nil
+	}
+	backup := fmt.Sprintf("%s.v%d-%s.bak", path, from, time.Now().Format("20060102-150405"))
+	if _, err := db.Exec(`VACUUM INTO ?`, backup); err != nil {
+		return fmt.Errorf("failed to back up database before migrating: %w", err)
+	}
+	return nil
+}
+
+// hasColumn reports whether table has the named column.
+func hasColumn(tx *sql.Tx, table, column string) (bool, error) {
+	rows, err := tx.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
 }
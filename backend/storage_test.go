@@ -0,0 +1,156 @@
+package backend
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// baselineSchema is the schema databases were created with before migrations were
+// versioned; such databases report user_version 0.
+const baselineSchema = `
+CREATE TABLE IF NOT EXISTS repertoire (
+  id       INTEGER PRIMARY KEY AUTOINCREMENT,
+  name     TEXT NOT NULL UNIQUE,
+  color    TEXT NOT NULL CHECK (color IN ('white','black')),
+  elo      INTEGER NOT NULL DEFAULT 1200,
+  coverage REAL NOT NULL DEFAULT 0.0
+);
+CREATE TABLE IF NOT EXISTS nodes (
+  fen         TEXT NOT NULL,
+  rep_id      INTEGER NOT NULL,
+  sr_index    INTEGER NOT NULL DEFAULT 0,
+  due         INTEGER,
+  last_review INTEGER,
+  PRIMARY KEY (fen, rep_id),
+  FOREIGN KEY (rep_id) REFERENCES repertoire(id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS stats (
+  fen        TEXT PRIMARY KEY,
+  games      INTEGER NOT NULL DEFAULT 0,
+  white_win  REAL NOT NULL DEFAULT 0.0,
+  black_win  REAL NOT NULL DEFAULT 0.0,
+  draw       REAL NOT NULL DEFAULT 0.0,
+  moves      TEXT NOT NULL DEFAULT '[]'
+);
+CREATE TABLE IF NOT EXISTS edges (
+  rep_id     INTEGER NOT NULL,
+  parent_fen TEXT NOT NULL,
+  child_fen  TEXT NOT NULL,
+  move       TEXT NOT NULL,
+  PRIMARY KEY (rep_id, parent_fen, child_fen),
+  FOREIGN KEY (rep_id) REFERENCES repertoire(id) ON DELETE CASCADE
+);
+`
+
+// openRaw opens a database file without migrating it.
+func openRaw(t *testing.T, path string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", "file:"+path+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// mustExec runs each statement, failing the test on the first error.
+func mustExec(t *testing.T, db *sql.DB, stmts ...string) {
+	t.Helper()
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+}
+
+func TestMigrateBaselineDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repertoire.db")
+	db := openRaw(t, path)
+	e4, err := ApplyMoveSAN(StartFEN, "e4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, db,
+		baselineSchema,
+		`INSERT INTO repertoire (name, color, elo, coverage) VALUES ('Main', 'white', 1500, 0.0)`,
+		`INSERT INTO nodes (fen, rep_id, sr_index, due, last_review) VALUES ('`+StartFEN+`', 1, 2, '2024-01-05 10:00:00', NULL)`,
+		`INSERT INTO nodes (fen, rep_id, sr_index, due, last_review) VALUES ('`+e4+`', 1, 0, NULL, NULL)`,
+		`INSERT INTO edges (rep_id, parent_fen, child_fen, move) VALUES (1, '`+StartFEN+`', '`+e4+`', 'e4')`,
+	)
+
+	if err := migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	version, err := schemaVersion(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != len(migrations) {
+		t.Errorf("user_version = %d, want %d", version, len(migrations))
+	}
+
+	var name, color string
+	var elo int
+	if err := db.QueryRow(`SELECT name, color, elo FROM repertoire WHERE id = 1`).Scan(&name, &color, &elo); err != nil {
+		t.Fatalf("repertoire lost: %v", err)
+	}
+	if name != "Main" || color != "white" || elo != 1500 {
+		t.Errorf("repertoire = %s %s %d, want Main white 1500", name, color, elo)
+	}
+	var box int
+	var due string
+	err = db.QueryRow(`SELECT sr_index, due FROM nodes WHERE rep_id = 1 AND fen = ?`, startKey).Scan(&box, &due)
+	if err != nil {
+		t.Fatalf("start node lost: %v", err)
+	}
+	if box != 2 || due != "2024-01-05 10:00:00" {
+		t.Errorf("start node = box %d due %q, want box 2 due 2024-01-05 10:00:00", box, due)
+	}
+	var move, kind string
+	err = db.QueryRow(`SELECT move, kind FROM edges WHERE rep_id = 1 AND parent_fen = ? AND child_fen = ?`,
+		startKey, positionKey(e4)).Scan(&move, &kind)
+	if err != nil {
+		t.Fatalf("edge lost: %v", err)
+	}
+	if move != "e4" || kind != EdgePrimary {
+		t.Errorf("edge = %s %s, want e4 %s", move, kind, EdgePrimary)
+	}
+
+	backups, err := filepath.Glob(path + ".v0-*.bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("found %d backups, want 1", len(backups))
+	}
+	backup := openRaw(t, backups[0])
+	if v, err := schemaVersion(backup); err != nil || v != 0 {
+		t.Errorf("backup user_version = %d (%v), want 0", v, err)
+	}
+	var nodes int
+	if err := backup.QueryRow(`SELECT COUNT(*) FROM nodes`).Scan(&nodes); err != nil || nodes != 2 {
+		t.Errorf("backup has %d nodes (%v), want 2", nodes, err)
+	}
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	db := openRaw(t, filepath.Join(t.TempDir(), "repertoire.db"))
+	for i := 0; i < 2; i++ {
+		if err := migrate(db); err != nil {
+			t.Fatalf("migrate #%d: %v", i+1, err)
+		}
+	}
+	if v, err := schemaVersion(db); err != nil || v != len(migrations) {
+		t.Errorf("user_version = %d (%v), want %d", v, err, len(migrations))
+	}
+}
+
+func TestMigrateRejectsNewerSchema(t *testing.T) {
+	db := openRaw(t, filepath.Join(t.TempDir(), "repertoire.db"))
+	mustExec(t, db, `PRAGMA user_version = 1000`)
+	if err := migrate(db); err == nil {
+		t.Fatal("migrate accepted a schema newer than the application")
+	}
+}
@@ -0,0 +1,121 @@
+package backend
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// SubtreeDeletion describes the positions removed, or that would be removed, with a move.
+type SubtreeDeletion struct {
+	Move      string   `json:"move"`
+	ChildFEN  string   `json:"childFen"`
+	Positions []string `json:"positions"` // in breadth-first order from the child
+	Count     int      `json:"count"`
+	DryRun    bool     `json:"dryRun"`
+}
+
+// DeleteSubtree removes moveSAN from the current position together with every position
+// that can only be reached through it. Positions still reachable another way, such as
+// transpositions, are kept. With dryRun set nothing is changed and the report lists what
+// would be removed.
+func (m *RepertoireManager) DeleteSubtree(moveSAN string, dryRun bool) (SubtreeDeletion, error) {
+	repID, fen, err := m.currentPosition()
+	if err != nil {
+		return SubtreeDeletion{}, err
+	}
+
+	ctx, done := m.beginOperation()
+	defer done()
+
+	var res SubtreeDeletion
+	parentKey, moveSAN := positionKey(fen), canonicalSAN(fen, moveSAN)
+	err = m.inTx(ctx, func(tx *sql.Tx) error {
+		g, err := loadGraph(ctx, tx, repID)
+		if err != nil {
+			return err
+		}
+		res, err = planSubtreeDeletion(g, parentKey, moveSAN)
+		if err != nil || dryRun {
+			return err
+		}
+		return deleteSubtree(ctx, tx, repID, parentKey, res)
+	})
+	if err != nil {
+		return SubtreeDeletion{}, err
+	}
+	res.DryRun = dryRun
+	return res, nil
+}
+
+// planSubtreeDeletion finds the positions that become unreachable once the move is gone.
+// Reachability is measured from the start position and the recorded roots, so lines
+// imported from a custom FEN keep their own root.
+func planSubtreeDeletion(g *repGraph, parentFEN, moveSAN string) (SubtreeDeletion, error) {
+	var cut *Edge
+	for i, e := range g.children[parentFEN] {
+		if e.MoveSAN == moveSAN {
+			cut = &g.children[parentFEN][i]
+			break
+		}
+	}
+	if cut == nil {
+		return SubtreeDeletion{}, fmt.Errorf("edge not found")
+	}
+
+	roots := g.roots()
+	kept := make([]Edge, 0, len(g.children[parentFEN])-1)
+	for _, e := range g.children[parentFEN] {
+		if e != *cut {
+			kept = append(kept, e)
+		}
+	}
+	res := SubtreeDeletion{Move: cut.MoveSAN, ChildFEN: cut.ChildFEN, Positions: []string{}}
+	g.children[parentFEN] = kept
+
+	alive := g.reachable(roots...)
+	seen := map[string]bool{cut.ChildFEN: true}
+	queue := []string{cut.ChildFEN}
+	for len(queue) > 0 {
+		fen := queue[0]
+		queue = queue[1:]
+		if alive[fen] {
+			continue
+		}
+		res.Positions = append(res.Positions, fen)
+		for _, e := range g.children[fen] {
+			if !seen[e.ChildFEN] {
+				seen[e.ChildFEN] = true
+				queue = append(queue, e.ChildFEN)
+			}
+		}
+	}
+	res.Count = len(res.Positions)
+	return res, nil
+}
+
+func deleteSubtree(ctx context.Context, tx *sql.Tx, repID int64, parentFEN string, res SubtreeDeletion) error {
+	_, err := tx.ExecContext(ctx,
+		`DELETE FROM edges WHERE rep_id = ? AND parent_fen = ? AND child_fen = ? AND move = ?`,
+		repID, parentFEN, res.ChildFEN, res.Move)
+	if err != nil {
+		return fmt.Errorf("failed to delete move: %w", err)
+	}
+	if err := ensurePrimary(ctx, tx, repID, parentFEN); err != nil {
+		return err
+	}
+	for _, fen := range res.Positions {
+		if err := checkContext(ctx); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM edges WHERE rep_id = ? AND parent_fen = ?`, repID, fen)
+		if err != nil {
+			return fmt.Errorf("failed to delete moves: %w", err)
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM nodes WHERE rep_id = ? AND fen = ?`, repID, fen)
+		if err != nil {
+			return fmt.Errorf("failed to delete position: %w", err)
+		}
+	}
+	return nil
+}
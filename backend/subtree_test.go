@@ -0,0 +1,87 @@
+package backend
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDeleteEdgeRemovesSubtree(t *testing.T) {
+	m, repID := newTestManager(t)
+	addLine(t, m, "e4", "e5", "Nf3", "Nc6", "Bb5")
+	addLine(t, m, "e4", "c5")
+	line := playLine(t, "e4", "e5", "Nf3", "Nc6", "Bb5")
+
+	m.SetCurrentFEN(line[1])
+	if err := m.DeleteEdge("Nf3"); err != nil {
+		t.Fatal(err)
+	}
+	for _, fen := range line[2:] {
+		if nodeExists(t, m, repID, fen) {
+			t.Errorf("position %s left behind", fen)
+		}
+	}
+	if !nodeExists(t, m, repID, line[1]) {
+		t.Error("parent position deleted")
+	}
+	if moves, err := m.ListEdges(); err != nil || len(moves) != 0 {
+		t.Errorf("moves after 1...e5 = %q, %v, want none", moves, err)
+	}
+	if report, err := m.CheckIntegrity(repID, false); err != nil || !report.OK() {
+		t.Errorf("integrity after delete: %+v, %v", report, err)
+	}
+	if err := m.DeleteEdge("Nf3"); err == nil {
+		t.Error("deleting a missing move succeeded")
+	}
+}
+
+func TestDeleteSubtreeKeepsTranspositions(t *testing.T) {
+	m, repID := newTestManager(t)
+	addLine(t, m, "e4", "e5", "Nf3", "Nc6", "Bb5")
+	addLine(t, m, "Nf3", "Nc6", "e4", "e5")
+	line := playLine(t, "e4", "e5", "Nf3", "Nc6", "Bb5")
+	other := playLine(t, "Nf3", "Nc6", "e4", "e5")
+	if positionKey(line[3]) != positionKey(other[3]) {
+		t.Fatal("test lines do not transpose")
+	}
+
+	m.SetCurrentFEN(StartFEN)
+	preview, err := m.DeleteSubtree("e2e4", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := keys(line[:3]...)
+	got := slices.Sorted(slices.Values(preview.Positions))
+	if !preview.DryRun || preview.Move != "e4" || preview.Count != 3 || !slices.Equal(got, want) {
+		t.Errorf("preview = %+v, want a dry run of e4 removing %q", preview, want)
+	}
+	if preview.Positions[0] != positionKey(line[0]) {
+		t.Errorf("preview starts at %s, want the position after e4", preview.Positions[0])
+	}
+	if !nodeExists(t, m, repID, line[0]) {
+		t.Fatal("dry run deleted positions")
+	}
+
+	res, err := m.DeleteSubtree("e4", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.DryRun || res.Count != 3 {
+		t.Errorf("deletion = %+v, want 3 positions removed", res)
+	}
+	for _, fen := range line[:3] {
+		if nodeExists(t, m, repID, fen) {
+			t.Errorf("position %s only reached through e4 kept", fen)
+		}
+	}
+	for _, fen := range append(other, line[4]) {
+		if !nodeExists(t, m, repID, fen) {
+			t.Errorf("position %s reached through 1.Nf3 deleted", fen)
+		}
+	}
+	if moves, err := m.ListEdges(); err != nil || !slices.Equal(moves, []string{"Nf3"}) {
+		t.Errorf("moves from the start = %q, %v, want [Nf3]", moves, err)
+	}
+	if report, err := m.CheckIntegrity(repID, false); err != nil || !report.OK() {
+		t.Errorf("integrity after delete: %+v, %v", report, err)
+	}
+}
@@ -0,0 +1,356 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// How a training session picks the opponent's reply when the repertoire has several.
+const (
+	RepliesExplorer = "explorer" // weighted by how often each reply is played, per the explorer
+	RepliesUniform  = "uniform"  // uniformly among the stored replies
+)
+
+// dueNodesWhere selects the due nodes of a repertoire (first argument) at a time (second
+// argument) where the repertoire's side is to move; nodes are aliased n. The side to move
+// is the second FEN field, so " w " cannot occur anywhere else.
+const dueNodesWhere = `n.rep_id = ? AND n.due <= ?
+	 AND (CASE WHEN n.fen LIKE '% w %' THEN 'white' ELSE 'black' END) =
+	     (SELECT color FROM repertoire WHERE id = n.rep_id)`
+
+// sideToMove returns "white" or "black" for a FEN.
+func sideToMove(fen string) string {
+	if fields := strings.Fields(fen); len(fields) > 1 && fields[1] == "b" {
+		return "black"
+	}
+	return "white"
+}
+
+// repertoireColor returns the side a repertoire is played from.
+func (m *RepertoireManager) repertoireColor(ctx context.Context, repID int64) (string, error) {
+	var color string
+	err := m.db.QueryRowContext(ctx, `SELECT color FROM repertoire WHERE id = ?`, repID).Scan(&color)
+	if err != nil {
+		return "", fmt.Errorf("failed to get repertoire color: %w", err)
+	}
+	return color, nil
+}
+
+// TrainingState is what the UI needs to show a training session.
+type TrainingState struct {
+	Active     bool         `json:"active"`
+	Color      string       `json:"color"`      // side the user plays
+	FEN        string       `json:"fen"`        // position awaiting the user's move
+	Line       []string     `json:"line"`       // moves from the start position, or the root FEN hangs off, to FEN
+	AutoPlayed []string     `json:"autoPlayed"` // moves played for the user since the last answer
+	LastAnswer *GradeResult `json:"lastAnswer"`
+	Answered   int          `json:"answered"`
+	Total      int          `json:"total"`
+	Correct    int          `json:"correct"`
+	Incorrect  int          `json:"incorrect"`
+	Finished   bool         `json:"finished"`
+}
+
+// trainingItem is a due position and the shortest line leading to it.
+type trainingItem struct {
+	fen     string // position key
+	display string // full FEN shown to the user
+	reach   float64
+	path    []Edge // from the start position or the recorded root the position hangs off
+}
+
+// trainingSession walks the due positions of a repertoire in line order.
+type trainingSession struct {
+	repID      int64
+	color      string
+	replies    string
+	queue      []trainingItem
+	next       int    // index of the item being asked
+	path       []Edge // line played so far in the current game
+	autoPlayed []string
+	last       *GradeResult
+	correct    int
+	incorrect  int
+}
+
+// StartTraining builds a queue of the selected repertoire's due positions where the
+// repertoire side is to move and plays the line to the first one from the start
+// position. Positions are visited in repertoire order. After each correct answer the
+// opponent's reply is played, chosen as replies says ("explorer" by default, or
+// "uniform"), and the line continues if that reaches another due position.
+func (m *RepertoireManager) StartTraining(replies string) (TrainingState, error) {
+	repID, err := m.selected()
+	if err != nil {
+		return TrainingState{}, err
+	}
+	switch replies {
+	case "":
+		replies = RepliesExplorer
+	case RepliesExplorer, RepliesUniform:
+	default:
+		return TrainingState{}, fmt.Errorf("unknown reply mode %q", replies)
+	}
+	ctx, done := m.beginOperation()
+	defer done()
+
+	color, err := m.repertoireColor(ctx, repID)
+	if err != nil {
+		return TrainingState{}, canceledError(err)
+	}
+	queue, err := m.trainingQueue(ctx, repID)
+	if err != nil {
+		return TrainingState{}, canceledError(err)
+	}
+	m.trainMu.Lock()
+	defer m.trainMu.Unlock()
+	m.session = &trainingSession{repID: repID, color: color, replies: replies, queue: queue}
+	m.advanceTraining()
+	return m.trainingState(), nil
+}
+
+// GetTrainingState reports the progress of the current training session.
+func (m *RepertoireManager) GetTrainingState() TrainingState {
+	m.trainMu.Lock()
+	defer m.trainMu.Unlock()
+	return m.trainingState()
+}
+
+// StopTraining ends the training session; answers already given stay recorded.
+func (m *RepertoireManager) StopTraining() {
+	m.trainMu.Lock()
+	defer m.trainMu.Unlock()
+	m.session = nil
+}
+
+// TrainingMove answers the current training position with moveSAN. The answer is
+// graded and logged, then the session moves on to the next due position, playing
+// the opponent's reply and any other moves that lead there.
+func (m *RepertoireManager) TrainingMove(moveSAN string) (TrainingState, error) {
+	m.trainMu.Lock()
+	defer m.trainMu.Unlock()
+	s := m.session
+	if s == nil || s.next >= len(s.queue) {
+		return TrainingState{}, fmt.Errorf("no training in progress")
+	}
+	if repID, _ := m.selected(); s.repID != repID {
+		return TrainingState{}, fmt.Errorf("training belongs to another repertoire")
+	}
+	ctx := m.baseContext()
+	item := s.queue[s.next]
+
+	res, childFEN, err := m.gradeMove(ctx, s.repID, item.fen, moveSAN)
+	if err != nil {
+		return TrainingState{}, err
+	}
+
+	s.last = &res
+	s.path = slices.Clone(item.path)
+	if res.Correct {
+		s.correct++
+		s.path = append(s.path, Edge{RepID: s.repID, ParentFEN: item.fen, ChildFEN: childFEN, MoveSAN: res.Primary, Kind: EdgePrimary})
+		g, err := loadGraph(ctx, m.db, s.repID)
+		if err != nil {
+			return TrainingState{}, canceledError(err)
+		}
+		m.setFEN(g.displayFEN(childFEN))
+		g.withoutDeprecated()
+		if replies := g.children[childFEN]; len(replies) > 0 {
+			reply := m.chooseReply(ctx, s, g.displayFEN(childFEN), replies)
+			s.continueLine(slices.Concat(s.path, []Edge{reply}), g.displayFEN(reply.ChildFEN))
+		}
+	} else {
+		s.incorrect++
+	}
+	s.next++
+	m.advanceTraining()
+	return m.trainingState(), nil
+}
+
+// continueLine asks the position at the end of path next if it is still queued,
+// so the line being played carries on instead of restarting.
+func (s *trainingSession) continueLine(path []Edge, display string) {
+	fen := path[len(path)-1].ChildFEN
+	for i := s.next + 1; i < len(s.queue); i++ {
+		if s.queue[i].fen == fen {
+			item := trainingItem{fen: fen, display: display, path: path}
+			copy(s.queue[s.next+2:i+1], s.queue[s.next+1:i])
+			s.queue[s.next+1] = item
+			return
+		}
+	}
+}
+
+// chooseReply picks the opponent's move among the stored replies in fen. With explorer
+// weighting, replies are drawn in proportion to their game counts; if the explorer
+// fails or knows none of them, every reply is equally likely.
+func (m *RepertoireManager) chooseReply(ctx context.Context, s *trainingSession, fen string, replies []Edge) Edge {
+	weights := make([]float64, len(replies))
+	total := 0.0
+	if s.replies == RepliesExplorer {
+		if q, err := m.repertoireExplorerQuery(ctx, s.repID); err == nil {
+			if data, err := m.explorer.Explore(ctx, fen, q); err == nil {
+				for i, e := range replies {
+					for _, mv := range data.Moves {
+						if mv.SAN == e.MoveSAN {
+							weights[i] = float64(mv.White + mv.Black + mv.Draws)
+						}
+					}
+					total += weights[i]
+				}
+			}
+		}
+	}
+	if total == 0 {
+		return replies[rand.Intn(len(replies))]
+	}
+	r := rand.Float64() * total
+	for i, w := range weights {
+		if r < w {
+			return replies[i]
+		}
+		r -= w
+	}
+	return replies[len(replies)-1]
+}
+
+// advanceTraining moves to the next queued position. When it continues the line just
+// played only the missing moves are auto-played; otherwise the line restarts.
+func (m *RepertoireManager) advanceTraining() {
+	s := m.session
+	s.autoPlayed = []string{}
+	if s.next >= len(s.queue) {
+		return
+	}
+	item := s.queue[s.next]
+	from := 0
+	if len(s.path) <= len(item.path) && slices.EqualFunc(s.path, item.path[:len(s.path)], sameMove) {
+		from = len(s.path)
+	}
+	for _, e := range item.path[from:] {
+		s.autoPlayed = append(s.autoPlayed, e.MoveSAN)
+	}
+	s.path = slices.Clone(item.path)
+	m.setFEN(item.display)
+}
+
+func sameMove(a, b Edge) bool {
+	return a.ParentFEN == b.ParentFEN && a.MoveSAN == b.MoveSAN
+}
+
+func (m *RepertoireManager) trainingState() TrainingState {
+	s := m.session
+	if s == nil {
+		return TrainingState{Line: []string{}, AutoPlayed: []string{}}
+	}
+	st := TrainingState{
+		Active:     s.next < len(s.queue),
+		Color:      s.color,
+		Line:       make([]string, 0, len(s.path)),
+		AutoPlayed: s.autoPlayed,
+		LastAnswer: s.last,
+		Answered:   s.next,
+		Total:      len(s.queue),
+		Correct:    s.correct,
+		Incorrect:  s.incorrect,
+		Finished:   s.next >= len(s.queue),
+	}
+	for _, e := range s.path {
+		st.Line = append(st.Line, e.MoveSAN)
+	}
+	if st.Active {
+		st.FEN = s.queue[s.next].display
+	}
+	return st
+}
+
+// trainingQueue returns the due positions that have a move to answer and can be
+// reached from the start position or a recorded root without deprecated moves, in
+// depth-first repertoire order with the most likely lines first once UpdateReach has
+// run. These are the positions CountDueNodes counts and GetDueFENs lists.
+func (m *RepertoireManager) trainingQueue(ctx context.Context, repID int64) ([]trainingItem, error) {
+	rows, err := m.db.QueryContext(ctx,
+		`SELECT n.fen FROM nodes n WHERE `+dueNodesWhere, repID, m.nowSQL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch due FENs: %w", err)
+	}
+	due := make(map[string]bool)
+	for rows.Next() {
+		var fen string
+		if err := rows.Scan(&fen); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		due[fen] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	g, err := loadGraph(ctx, m.db, repID)
+	if err != nil {
+		return nil, err
+	}
+	var queue []trainingItem
+	for _, p := range g.withoutDeprecated().shortestPaths(g.roots()...) {
+		if due[p.fen] && len(g.children[p.fen]) > 0 {
+			p.display = g.displayFEN(p.fen)
+			p.reach = g.reach[p.fen]
+			queue = append(queue, p)
+		}
+	}
+	return queue, nil
+}
+
+// shortestPaths returns every position reachable from the roots with a shortest line
+// to it from one of them, ordered depth-first along the first line found to each
+// position, one root after the other, visiting the children of a position in
+// decreasing order of reach.
+func (g *repGraph) shortestPaths(roots ...string) []trainingItem {
+	via := map[string]Edge{}
+	tree := map[string][]string{}
+	seen := map[string]bool{}
+	var queue []string
+	for _, root := range roots {
+		if !seen[root] {
+			seen[root] = true
+			queue = append(queue, root)
+		}
+	}
+	roots = slices.Clone(queue)
+	for len(queue) > 0 {
+		fen := queue[0]
+		queue = queue[1:]
+		for _, e := range g.children[fen] {
+			if !seen[e.ChildFEN] {
+				seen[e.ChildFEN] = true
+				via[e.ChildFEN] = e
+				tree[fen] = append(tree[fen], e.ChildFEN)
+				queue = append(queue, e.ChildFEN)
+			}
+		}
+	}
+
+	for _, children := range tree {
+		sort.SliceStable(children, func(i, j int) bool {
+			return g.reach[children[i]] > g.reach[children[j]]
+		})
+	}
+
+	var out []trainingItem
+	var walk func(fen string, path []Edge)
+	walk = func(fen string, path []Edge) {
+		out = append(out, trainingItem{fen: fen, path: path})
+		for _, child := range tree[fen] {
+			walk(child, append(slices.Clone(path), via[child]))
+		}
+	}
+	for _, root := range roots {
+		walk(root, []Edge{})
+	}
+	return out
+}
@@ -0,0 +1,125 @@
+package backend
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// newTrainingManager returns a manager with a white repertoire holding 1.e4 e5 2.Nf3,
+// 1.e4 c5 2.Nf3, an imported line from the Ruy Lopez and a line cut off from every
+// root, all due, and an explorer that always answers 1.e4 with 1...e5.
+func newTrainingManager(t *testing.T) (*RepertoireManager, int64) {
+	t.Helper()
+	fe := NewFakeExplorer()
+	m, repID := newTestManager(t, WithExplorer(fe), WithClock(func() time.Time { return testNow }))
+	addLine(t, m, "e4", "e5", "Nf3")
+	addLine(t, m, "e4", "c5", "Nf3")
+	addLine(t, m, "d4", "d5", "c4")
+	_, err := m.ImportPGN(repID, `[Event "Ruy Lopez"]
+[SetUp "1"]
+[FEN "r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3"]
+
+3. Bb5 *`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.db.Exec(`DELETE FROM edges WHERE rep_id = ? AND parent_fen = ? AND move = 'd4'`, repID, startKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fe.Responses[playLine(t, "e4")[0]] = explorerMoves(map[string]int{"e5": 100})
+	return m, repID
+}
+
+const ruyLopez = "r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3"
+
+func TestDuePositionsMatchTraining(t *testing.T) {
+	m, repID := newTrainingManager(t)
+	e5 := playLine(t, "e4", "e5")[1]
+	c5 := playLine(t, "e4", "c5")[1]
+	if _, err := m.UpdateReach(repID); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := m.CountDueNodes(repID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fens, err := m.GetDueFENs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1...c5 is never played per the explorer, so it comes last.
+	if want := []string{StartFEN, e5, ruyLopez, c5}; !slices.Equal(fens, want) {
+		t.Errorf("due FENs = %q, want %q", fens, want)
+	}
+	st, err := m.StartTraining(RepliesExplorer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 || st.Total != n {
+		t.Errorf("%d due positions, training asks %d, want 4 for both", n, st.Total)
+	}
+}
+
+func TestTrainingSession(t *testing.T) {
+	m, repID := newTrainingManager(t)
+	e5 := playLine(t, "e4", "e5")[1]
+	c5 := playLine(t, "e4", "c5")[1]
+
+	st, err := m.StartTraining(RepliesExplorer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.Active || st.Color != "white" || st.FEN != StartFEN || len(st.Line) != 0 || st.Total != 4 {
+		t.Fatalf("start = %+v, want 4 positions from the start position", st)
+	}
+
+	steps := []struct {
+		move       string
+		correct    bool
+		fen        string
+		line       []string
+		autoPlayed []string
+	}{
+		// The reply 1...e5 is played and its line continues.
+		{"e4", true, e5, []string{"e4", "e5"}, []string{"e5"}},
+		{"d4", false, c5, []string{"e4", "c5"}, []string{"e4", "c5"}},
+		{"Nf3", true, ruyLopez, []string{}, []string{}},
+		{"Bb5", true, "", []string{"Bb5"}, []string{}},
+	}
+	for i, step := range steps {
+		st, err = m.TrainingMove(step.move)
+		if err != nil {
+			t.Fatalf("answer %d (%s): %v", i+1, step.move, err)
+		}
+		if st.LastAnswer == nil || st.LastAnswer.Correct != step.correct {
+			t.Errorf("answer %d (%s): result %+v, want correct %v", i+1, step.move, st.LastAnswer, step.correct)
+		}
+		if st.FEN != step.fen || !slices.Equal(st.Line, step.line) || !slices.Equal(st.AutoPlayed, step.autoPlayed) {
+			t.Errorf("after answer %d (%s): fen %q line %q auto-played %q, want %q %q %q",
+				i+1, step.move, st.FEN, st.Line, st.AutoPlayed, step.fen, step.line, step.autoPlayed)
+		}
+		if st.Answered != i+1 {
+			t.Errorf("after answer %d: %d answered", i+1, st.Answered)
+		}
+	}
+	if st.Active || !st.Finished || st.Correct != 3 || st.Incorrect != 1 {
+		t.Errorf("end = %+v, want a finished session with 3 correct and 1 incorrect", st)
+	}
+	if st.LastAnswer.Primary != "Bb5" || !slices.Equal(st.LastAnswer.Expected, []string{"Bb5"}) {
+		t.Errorf("last answer = %+v, want Bb5 expected", st.LastAnswer)
+	}
+	if _, err := m.TrainingMove("a4"); err == nil {
+		t.Error("answer accepted after the session finished")
+	}
+	if n, err := m.CountDueNodes(repID); err != nil || n != 0 {
+		t.Errorf("%d positions still due (%v), want 0 after training", n, err)
+	}
+
+	m.StopTraining()
+	if st := m.GetTrainingState(); st.Active || st.Total != 0 {
+		t.Errorf("state after stopping = %+v", st)
+	}
+}
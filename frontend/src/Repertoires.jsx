@@ -115,10 +115,14 @@ export default function Repertoires({ onSelect, onResetFen, setRefreshRepertoire
 
     async function submitPracticeMove() {
         try {
-            await RepMgr.TestCurrentPositionWithDueDate(practiceMove);
-            alert("Move is correct!");
+            const result = await RepMgr.TestCurrentPositionWithDueDate(practiceMove);
+            if (result.correct) {
+                alert("Move is correct!");
+            } else {
+                alert(`Incorrect move, expected: ${result.expected.join(", ")}`);
+            }
         } catch (e) {
-            alert(`Incorrect move: ${e.message}`);
+            alert(`Could not check move: ${e}`);
         } finally {
             setPracticeFEN(null); // Close practice window
             setPracticeMove(""); // Reset move input
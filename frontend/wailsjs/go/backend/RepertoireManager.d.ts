@@ -4,6 +4,16 @@ import {backend} from '../models';
 
 export function AddEdge(arg1:string):Promise<void>;
 
+export function AnalyzeCurrentPosition(arg1:backend.AnalysisLimits):Promise<void>;
+
+export function BuildNewRepertoire(arg1:string,arg2:string,arg3:number,arg4:backend.BuildOptions):Promise<backend.BuildResult>;
+
+export function BuildRepertoire(arg1:number,arg2:backend.BuildOptions):Promise<backend.BuildResult>;
+
+export function CancelOperations():Promise<void>;
+
+export function CheckIntegrity(arg1:number,arg2:boolean):Promise<backend.IntegrityReport>;
+
 export function CountDueNodes(arg1:number):Promise<number>;
 
 export function Create(arg1:string,arg2:string,arg3:number):Promise<number>;
@@ -12,6 +22,12 @@ export function Delete(arg1:number):Promise<void>;
 
 export function DeleteEdge(arg1:string):Promise<void>;
 
+export function DeleteSubtree(arg1:string,arg2:boolean):Promise<backend.SubtreeDeletion>;
+
+export function ExportPGN(arg1:number):Promise<string>;
+
+export function FindCoverageGaps(arg1:number):Promise<Array<backend.CoverageGap>>;
+
 export function GetCurrentElo():Promise<number>;
 
 export function GetCurrentFEN():Promise<string>;
@@ -24,22 +40,86 @@ export function GetCurrentWinrates():Promise<backend.PositionWinrate>;
 
 export function GetDueFENs():Promise<Array<string>>;
 
+export function GetEngineInfo():Promise<backend.EngineInfo>;
+
+export function GetExplorerQuery():Promise<backend.ExplorerQuery>;
+
+export function GetExplorerSource():Promise<backend.ExplorerQuery>;
+
+export function GetFailingPositions(arg1:number,arg2:number):Promise<Array<backend.PositionHistory>>;
+
+export function GetMoveAnnotation(arg1:string):Promise<backend.Annotation>;
+
+export function GetPerformanceReport(arg1:number):Promise<backend.PerformanceReport>;
+
+export function GetPositionAnnotation():Promise<backend.Annotation>;
+
+export function GetPositionHistory(arg1:string,arg2:number):Promise<Array<backend.ReviewEntry>>;
+
+export function GetReachTree(arg1:number,arg2:number):Promise<backend.ReachNode>;
+
+export function GetRepertoireHistory(arg1:number,arg2:number):Promise<Array<backend.ReviewEntry>>;
+
+export function GetScheduler(arg1:number):Promise<string>;
+
 export function GetSelectedID():Promise<number>;
 
+export function GetTrainingState():Promise<backend.TrainingState>;
+
+export function GradeCurrentPosition(arg1:number):Promise<backend.CardState>;
+
+export function ImportExplorerGames(arg1:string):Promise<number>;
+
+export function ImportPGN(arg1:number,arg2:string):Promise<backend.ImportResult>;
+
 export function List():Promise<Array<backend.Repertoire>>;
 
 export function ListEdges():Promise<Array<string>>;
 
+export function ListMoves():Promise<Array<backend.Edge>>;
+
 export function PlayMoveSAN(arg1:string):Promise<void>;
 
+export function PurgeExplorerCache(arg1:number):Promise<number>;
+
 export function SelectRepertoire(arg1:number):Promise<void>;
 
 export function SetCurrentFEN(arg1:string):Promise<void>;
 
 export function SetCurrentID(arg1:number):Promise<void>;
 
-export function TestCurrentPosition(arg1:string):Promise<void>;
+export function SetEdgeKind(arg1:string,arg2:string):Promise<void>;
+
+export function SetEngineOption(arg1:string,arg2:string):Promise<void>;
+
+export function SetExplorerQuery(arg1:number,arg2:backend.ExplorerQuery):Promise<void>;
+
+export function SetExplorerSource(arg1:string,arg2:string):Promise<void>;
+
+export function SetMoveAnnotation(arg1:string,arg2:backend.Annotation):Promise<void>;
+
+export function SetPositionAnnotation(arg1:backend.Annotation):Promise<void>;
 
-export function TestCurrentPositionWithDueDate(arg1:string):Promise<void>;
+export function SetScheduler(arg1:number,arg2:string):Promise<void>;
+
+export function StartEngine(arg1:backend.EngineConfig):Promise<backend.EngineInfo>;
+
+export function StartTraining(arg1:string):Promise<backend.TrainingState>;
+
+export function StopAnalysis():Promise<void>;
+
+export function StopEngine():Promise<void>;
+
+export function StopTraining():Promise<void>;
+
+export function TestCurrentPosition(arg1:string):Promise<backend.GradeResult>;
+
+export function TestCurrentPositionWithDueDate(arg1:string):Promise<backend.GradeResult>;
+
+export function TrainingMove(arg1:string):Promise<backend.TrainingState>;
 
 export function Update(arg1:backend.Repertoire):Promise<void>;
+
+export function UpdateReach(arg1:number):Promise<number>;
+
+export function WarmExplorerCache(arg1:number):Promise<number>;
@@ -6,6 +6,26 @@ export function AddEdge(arg1) {
   return window['go']['backend']['RepertoireManager']['AddEdge'](arg1);
 }
 
+export function AnalyzeCurrentPosition(arg1) {
+  return window['go']['backend']['RepertoireManager']['AnalyzeCurrentPosition'](arg1);
+}
+
+export function BuildNewRepertoire(arg1, arg2, arg3, arg4) {
+  return window['go']['backend']['RepertoireManager']['BuildNewRepertoire'](arg1, arg2, arg3, arg4);
+}
+
+export function BuildRepertoire(arg1, arg2) {
+  return window['go']['backend']['RepertoireManager']['BuildRepertoire'](arg1, arg2);
+}
+
+export function CancelOperations() {
+  return window['go']['backend']['RepertoireManager']['CancelOperations']();
+}
+
+export function CheckIntegrity(arg1, arg2) {
+  return window['go']['backend']['RepertoireManager']['CheckIntegrity'](arg1, arg2);
+}
+
 export function CountDueNodes(arg1) {
   return window['go']['backend']['RepertoireManager']['CountDueNodes'](arg1);
 }
@@ -22,6 +42,18 @@ export function DeleteEdge(arg1) {
   return window['go']['backend']['RepertoireManager']['DeleteEdge'](arg1);
 }
 
+export function DeleteSubtree(arg1, arg2) {
+  return window['go']['backend']['RepertoireManager']['DeleteSubtree'](arg1, arg2);
+}
+
+export function ExportPGN(arg1) {
+  return window['go']['backend']['RepertoireManager']['ExportPGN'](arg1);
+}
+
+export function FindCoverageGaps(arg1) {
+  return window['go']['backend']['RepertoireManager']['FindCoverageGaps'](arg1);
+}
+
 export function GetCurrentElo() {
   return window['go']['backend']['RepertoireManager']['GetCurrentElo']();
 }
@@ -46,10 +78,70 @@ export function GetDueFENs() {
   return window['go']['backend']['RepertoireManager']['GetDueFENs']();
 }
 
+export function GetEngineInfo() {
+  return window['go']['backend']['RepertoireManager']['GetEngineInfo']();
+}
+
+export function GetExplorerQuery() {
+  return window['go']['backend']['RepertoireManager']['GetExplorerQuery']();
+}
+
+export function GetExplorerSource() {
+  return window['go']['backend']['RepertoireManager']['GetExplorerSource']();
+}
+
+export function GetFailingPositions(arg1, arg2) {
+  return window['go']['backend']['RepertoireManager']['GetFailingPositions'](arg1, arg2);
+}
+
+export function GetMoveAnnotation(arg1) {
+  return window['go']['backend']['RepertoireManager']['GetMoveAnnotation'](arg1);
+}
+
+export function GetPerformanceReport(arg1) {
+  return window['go']['backend']['RepertoireManager']['GetPerformanceReport'](arg1);
+}
+
+export function GetPositionAnnotation() {
+  return window['go']['backend']['RepertoireManager']['GetPositionAnnotation']();
+}
+
+export function GetPositionHistory(arg1, arg2) {
+  return window['go']['backend']['RepertoireManager']['GetPositionHistory'](arg1, arg2);
+}
+
+export function GetReachTree(arg1, arg2) {
+  return window['go']['backend']['RepertoireManager']['GetReachTree'](arg1, arg2);
+}
+
+export function GetRepertoireHistory(arg1, arg2) {
+  return window['go']['backend']['RepertoireManager']['GetRepertoireHistory'](arg1, arg2);
+}
+
+export function GetScheduler(arg1) {
+  return window['go']['backend']['RepertoireManager']['GetScheduler'](arg1);
+}
+
 export function GetSelectedID() {
   return window['go']['backend']['RepertoireManager']['GetSelectedID']();
 }
 
+export function GetTrainingState() {
+  return window['go']['backend']['RepertoireManager']['GetTrainingState']();
+}
+
+export function GradeCurrentPosition(arg1) {
+  return window['go']['backend']['RepertoireManager']['GradeCurrentPosition'](arg1);
+}
+
+export function ImportExplorerGames(arg1) {
+  return window['go']['backend']['RepertoireManager']['ImportExplorerGames'](arg1);
+}
+
+export function ImportPGN(arg1, arg2) {
+  return window['go']['backend']['RepertoireManager']['ImportPGN'](arg1, arg2);
+}
+
 export function List() {
   return window['go']['backend']['RepertoireManager']['List']();
 }
@@ -58,10 +150,18 @@ export function ListEdges() {
   return window['go']['backend']['RepertoireManager']['ListEdges']();
 }
 
+export function ListMoves() {
+  return window['go']['backend']['RepertoireManager']['ListMoves']();
+}
+
 export function PlayMoveSAN(arg1) {
   return window['go']['backend']['RepertoireManager']['PlayMoveSAN'](arg1);
 }
 
+export function PurgeExplorerCache(arg1) {
+  return window['go']['backend']['RepertoireManager']['PurgeExplorerCache'](arg1);
+}
+
 export function SelectRepertoire(arg1) {
   return window['go']['backend']['RepertoireManager']['SelectRepertoire'](arg1);
 }
@@ -74,6 +174,54 @@ export function SetCurrentID(arg1) {
   return window['go']['backend']['RepertoireManager']['SetCurrentID'](arg1);
 }
 
+export function SetEdgeKind(arg1, arg2) {
+  return window['go']['backend']['RepertoireManager']['SetEdgeKind'](arg1, arg2);
+}
+
+export function SetEngineOption(arg1, arg2) {
+  return window['go']['backend']['RepertoireManager']['SetEngineOption'](arg1, arg2);
+}
+
+export function SetExplorerQuery(arg1, arg2) {
+  return window['go']['backend']['RepertoireManager']['SetExplorerQuery'](arg1, arg2);
+}
+
+export function SetExplorerSource(arg1, arg2) {
+  return window['go']['backend']['RepertoireManager']['SetExplorerSource'](arg1, arg2);
+}
+
+export function SetMoveAnnotation(arg1, arg2) {
+  return window['go']['backend']['RepertoireManager']['SetMoveAnnotation'](arg1, arg2);
+}
+
+export function SetPositionAnnotation(arg1) {
+  return window['go']['backend']['RepertoireManager']['SetPositionAnnotation'](arg1);
+}
+
+export function SetScheduler(arg1, arg2) {
+  return window['go']['backend']['RepertoireManager']['SetScheduler'](arg1, arg2);
+}
+
+export function StartEngine(arg1) {
+  return window['go']['backend']['RepertoireManager']['StartEngine'](arg1);
+}
+
+export function StartTraining(arg1) {
+  return window['go']['backend']['RepertoireManager']['StartTraining'](arg1);
+}
+
+export function StopAnalysis() {
+  return window['go']['backend']['RepertoireManager']['StopAnalysis']();
+}
+
+export function StopEngine() {
+  return window['go']['backend']['RepertoireManager']['StopEngine']();
+}
+
+export function StopTraining() {
+  return window['go']['backend']['RepertoireManager']['StopTraining']();
+}
+
 export function TestCurrentPosition(arg1) {
   return window['go']['backend']['RepertoireManager']['TestCurrentPosition'](arg1);
 }
@@ -82,6 +230,18 @@ export function TestCurrentPositionWithDueDate(arg1) {
   return window['go']['backend']['RepertoireManager']['TestCurrentPositionWithDueDate'](arg1);
 }
 
+export function TrainingMove(arg1) {
+  return window['go']['backend']['RepertoireManager']['TrainingMove'](arg1);
+}
+
 export function Update(arg1) {
   return window['go']['backend']['RepertoireManager']['Update'](arg1);
 }
+
+export function UpdateReach(arg1) {
+  return window['go']['backend']['RepertoireManager']['UpdateReach'](arg1);
+}
+
+export function WarmExplorerCache(arg1) {
+  return window['go']['backend']['RepertoireManager']['WarmExplorerCache'](arg1);
+}
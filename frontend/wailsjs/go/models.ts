@@ -1,5 +1,455 @@
 export namespace backend {
 	
+	export class AnalysisLimits {
+	    lines: number;
+	    depth: number;
+	    moveTime: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new AnalysisLimits(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.lines = source["lines"];
+	        this.depth = source["depth"];
+	        this.moveTime = source["moveTime"];
+	    }
+	}
+	export class Annotation {
+	    comment: string;
+	    nags: number[];
+	    arrows: string[];
+	    squares: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Annotation(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.comment = source["comment"];
+	        this.nags = source["nags"];
+	        this.arrows = source["arrows"];
+	        this.squares = source["squares"];
+	    }
+	}
+	export class BuildOptions {
+	    rootFen: string;
+	    depth: number;
+	    coverage: number;
+	    policy: string;
+	    minGames: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new BuildOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.rootFen = source["rootFen"];
+	        this.depth = source["depth"];
+	        this.coverage = source["coverage"];
+	        this.policy = source["policy"];
+	        this.minGames = source["minGames"];
+	    }
+	}
+	export class BuildResult {
+	    repId: number;
+	    newPositions: number;
+	    knownPositions: number;
+	    newMoves: number;
+	    knownMoves: number;
+	    unanswered: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new BuildResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.repId = source["repId"];
+	        this.newPositions = source["newPositions"];
+	        this.knownPositions = source["knownPositions"];
+	        this.newMoves = source["newMoves"];
+	        this.knownMoves = source["knownMoves"];
+	        this.unanswered = source["unanswered"];
+	    }
+	}
+	export class CardState {
+	    box: number;
+	    ease: number;
+	    interval: number;
+	    stability: number;
+	    difficulty: number;
+	    reps: number;
+	    lapses: number;
+	    // Go type: time
+	    due: any;
+	    // Go type: time
+	    lastReview: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new CardState(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.box = source["box"];
+	        this.ease = source["ease"];
+	        this.interval = source["interval"];
+	        this.stability = source["stability"];
+	        this.difficulty = source["difficulty"];
+	        this.reps = source["reps"];
+	        this.lapses = source["lapses"];
+	        this.due = this.convertValues(source["due"], null);
+	        this.lastReview = this.convertValues(source["lastReview"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class CoverageGap {
+	    fen: string;
+	    move: string;
+	    line: string[];
+	    probability: number;
+	    games: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new CoverageGap(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.fen = source["fen"];
+	        this.move = source["move"];
+	        this.line = source["line"];
+	        this.probability = source["probability"];
+	        this.games = source["games"];
+	    }
+	}
+	export class Edge {
+	    RepID: number;
+	    ParentFEN: string;
+	    ChildFEN: string;
+	    MoveSAN: string;
+	    Kind: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Edge(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.RepID = source["RepID"];
+	        this.ParentFEN = source["ParentFEN"];
+	        this.ChildFEN = source["ChildFEN"];
+	        this.MoveSAN = source["MoveSAN"];
+	        this.Kind = source["Kind"];
+	    }
+	}
+	export class EngineConfig {
+	    path: string;
+	    args: string[];
+	    options: Record<string, string>;
+	
+	    static createFrom(source: any = {}) {
+	        return new EngineConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.args = source["args"];
+	        this.options = source["options"];
+	    }
+	}
+	export class EngineOption {
+	    name: string;
+	    type: string;
+	    default: string;
+	    min: number;
+	    max: number;
+	    vars: string[];
+	    value: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new EngineOption(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.type = source["type"];
+	        this.default = source["default"];
+	        this.min = source["min"];
+	        this.max = source["max"];
+	        this.vars = source["vars"];
+	        this.value = source["value"];
+	    }
+	}
+	export class EngineInfo {
+	    running: boolean;
+	    analyzing: boolean;
+	    config: EngineConfig;
+	    name: string;
+	    author: string;
+	    options: EngineOption[];
+	
+	    static createFrom(source: any = {}) {
+	        return new EngineInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.running = source["running"];
+	        this.analyzing = source["analyzing"];
+	        this.config = this.convertValues(source["config"], EngineConfig);
+	        this.name = source["name"];
+	        this.author = source["author"];
+	        this.options = this.convertValues(source["options"], EngineOption);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class ExplorerQuery {
+	    database: string;
+	    variant: string;
+	    speeds: string[];
+	    ratings: number[];
+	    since: string;
+	    until: string;
+	    player: string;
+	    color: string;
+	    modes: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ExplorerQuery(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.database = source["database"];
+	        this.variant = source["variant"];
+	        this.speeds = source["speeds"];
+	        this.ratings = source["ratings"];
+	        this.since = source["since"];
+	        this.until = source["until"];
+	        this.player = source["player"];
+	        this.color = source["color"];
+	        this.modes = source["modes"];
+	    }
+	}
+	export class GradeResult {
+	    fen: string;
+	    move: string;
+	    correct: boolean;
+	    expected: string[];
+	    primary: string;
+	    alternate: boolean;
+	    grade: number;
+	    box: number;
+	    interval: number;
+	    // Go type: time
+	    due: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new GradeResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.fen = source["fen"];
+	        this.move = source["move"];
+	        this.correct = source["correct"];
+	        this.expected = source["expected"];
+	        this.primary = source["primary"];
+	        this.alternate = source["alternate"];
+	        this.grade = source["grade"];
+	        this.box = source["box"];
+	        this.interval = source["interval"];
+	        this.due = this.convertValues(source["due"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class IllegalMove {
+	    game: number;
+	    fen: string;
+	    san: string;
+	    line: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new IllegalMove(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.game = source["game"];
+	        this.fen = source["fen"];
+	        this.san = source["san"];
+	        this.line = source["line"];
+	    }
+	}
+	export class ImportResult {
+	    games: number;
+	    newPositions: number;
+	    knownPositions: number;
+	    newMoves: number;
+	    knownMoves: number;
+	    illegalMoves: IllegalMove[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ImportResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.games = source["games"];
+	        this.newPositions = source["newPositions"];
+	        this.knownPositions = source["knownPositions"];
+	        this.newMoves = source["newMoves"];
+	        this.knownMoves = source["knownMoves"];
+	        this.illegalMoves = this.convertValues(source["illegalMoves"], IllegalMove);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class IntegrityReport {
+	    missingNodes: string[];
+	    orphanNodes: string[];
+	    mismatchedEdges: Edge[];
+	    unreachableNodes: string[];
+	    repaired: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new IntegrityReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.missingNodes = source["missingNodes"];
+	        this.orphanNodes = source["orphanNodes"];
+	        this.mismatchedEdges = this.convertValues(source["mismatchedEdges"], Edge);
+	        this.unreachableNodes = source["unreachableNodes"];
+	        this.repaired = source["repaired"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class MovePerformance {
+	    fen: string;
+	    move: string;
+	    kind: string;
+	    reach: number;
+	    games: number;
+	    score: number;
+	    drawRate: number;
+	    positionScore: number;
+	    bestMove: string;
+	    bestScore: number;
+	    belowAverage: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new MovePerformance(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.fen = source["fen"];
+	        this.move = source["move"];
+	        this.kind = source["kind"];
+	        this.reach = source["reach"];
+	        this.games = source["games"];
+	        this.score = source["score"];
+	        this.drawRate = source["drawRate"];
+	        this.positionScore = source["positionScore"];
+	        this.bestMove = source["bestMove"];
+	        this.bestScore = source["bestScore"];
+	        this.belowAverage = source["belowAverage"];
+	    }
+	}
 	export class MoveWinrate {
 	    san: string;
 	    uci: string;
@@ -24,6 +474,88 @@ export namespace backend {
 	        this.chance = source["chance"];
 	    }
 	}
+	export class PerformanceReport {
+	    repId: number;
+	    elo: number;
+	    expectedScore: number;
+	    belowAverage: number;
+	    moves: MovePerformance[];
+	
+	    static createFrom(source: any = {}) {
+	        return new PerformanceReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.repId = source["repId"];
+	        this.elo = source["elo"];
+	        this.expectedScore = source["expectedScore"];
+	        this.belowAverage = source["belowAverage"];
+	        this.moves = this.convertValues(source["moves"], MovePerformance);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class PositionHistory {
+	    fen: string;
+	    reviews: number;
+	    failures: number;
+	    failureRate: number;
+	    avgResponseMs: number;
+	    // Go type: time
+	    lastReviewed: any;
+	    // Go type: time
+	    lastFailed: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new PositionHistory(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.fen = source["fen"];
+	        this.reviews = source["reviews"];
+	        this.failures = source["failures"];
+	        this.failureRate = source["failureRate"];
+	        this.avgResponseMs = source["avgResponseMs"];
+	        this.lastReviewed = this.convertValues(source["lastReviewed"], null);
+	        this.lastFailed = this.convertValues(source["lastFailed"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class PositionWinrate {
 	    total: number;
 	    whiteRate: number;
@@ -62,12 +594,54 @@ export namespace backend {
 		    return a;
 		}
 	}
+	export class ReachNode {
+	    fen: string;
+	    move: string;
+	    kind: string;
+	    reach: number;
+	    transposition: boolean;
+	    children: ReachNode[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ReachNode(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.fen = source["fen"];
+	        this.move = source["move"];
+	        this.kind = source["kind"];
+	        this.reach = source["reach"];
+	        this.transposition = source["transposition"];
+	        this.children = this.convertValues(source["children"], ReachNode);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class Repertoire {
 	    id: number;
 	    name: string;
 	    color: string;
 	    elo: number;
 	    coverage: number;
+	    explorer: ExplorerQuery;
+	    scheduler: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new Repertoire(source);
@@ -80,7 +654,146 @@ export namespace backend {
 	        this.color = source["color"];
 	        this.elo = source["elo"];
 	        this.coverage = source["coverage"];
+	        this.explorer = this.convertValues(source["explorer"], ExplorerQuery);
+	        this.scheduler = source["scheduler"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ReviewEntry {
+	    id: number;
+	    repId: number;
+	    fen: string;
+	    move: string;
+	    correct: boolean;
+	    grade: number;
+	    responseMs: number;
+	    before: CardState;
+	    after: CardState;
+	    // Go type: time
+	    reviewedAt: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new ReviewEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.repId = source["repId"];
+	        this.fen = source["fen"];
+	        this.move = source["move"];
+	        this.correct = source["correct"];
+	        this.grade = source["grade"];
+	        this.responseMs = source["responseMs"];
+	        this.before = this.convertValues(source["before"], CardState);
+	        this.after = this.convertValues(source["after"], CardState);
+	        this.reviewedAt = this.convertValues(source["reviewedAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SubtreeDeletion {
+	    move: string;
+	    childFen: string;
+	    positions: string[];
+	    count: number;
+	    dryRun: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new SubtreeDeletion(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.move = source["move"];
+	        this.childFen = source["childFen"];
+	        this.positions = source["positions"];
+	        this.count = source["count"];
+	        this.dryRun = source["dryRun"];
+	    }
+	}
+	export class TrainingState {
+	    active: boolean;
+	    color: string;
+	    fen: string;
+	    line: string[];
+	    autoPlayed: string[];
+	    lastAnswer?: GradeResult;
+	    answered: number;
+	    total: number;
+	    correct: number;
+	    incorrect: number;
+	    finished: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new TrainingState(source);
 	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.active = source["active"];
+	        this.color = source["color"];
+	        this.fen = source["fen"];
+	        this.line = source["line"];
+	        this.autoPlayed = source["autoPlayed"];
+	        this.lastAnswer = this.convertValues(source["lastAnswer"], GradeResult);
+	        this.answered = source["answered"];
+	        this.total = source["total"];
+	        this.correct = source["correct"];
+	        this.incorrect = source["incorrect"];
+	        this.finished = source["finished"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 
 }
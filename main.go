@@ -56,6 +56,7 @@ func main() {
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup:        app.startup,
+		OnShutdown:       app.shutdown,
         Bind:   []interface{}{app, app.RepMgr},
     }); err != nil {
         log.Fatal(err)